@@ -0,0 +1,207 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+// cacheKey returns the key of the path in the response cache. The
+// key doesn't depend on the node. The query is sorted.
+func cacheKey(s *specs.RestService, path, rawQuery string) string {
+	p, _, _ := strings.Cut(path, "?")
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	if q, err := url.ParseQuery(rawQuery); err == nil {
+		rawQuery = q.Encode()
+	}
+	ans := s.GetName() + " " + p
+	if rawQuery != "" {
+		ans += "?" + rawQuery
+	}
+	return ans
+}
+
+// ticketCacheKey returns the key of the request of the ticket. The
+// credentials set in the query by the authenticator of the node are
+// not part of the key.
+func ticketCacheKey(t *specs.RestTicket) string {
+	u := *t.Request.URL
+	if t.Node != nil {
+		if d, ok := t.Service.GetAuthenticator(t.Node).(specs.Deauthenticator); ok {
+			d.Deauthenticate(&http.Request{URL: &u, Header: http.Header{}})
+		}
+	}
+	return cacheKey(t.Service, t.Path, u.RawQuery)
+}
+
+// isCachedFresh checks if the cache of the service has a fresh
+// response for the path.
+func isCachedFresh(s *specs.RestService, path string) bool {
+	if !s.HasCache() {
+		return false
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return false
+	}
+	e, err := s.GetCache().Get(cacheKey(s, path, u.RawQuery))
+	return err == nil && e != nil && e.IsFresh(nil, time.Now())
+}
+
+// isUnsafeMethod checks if the method could modify the resource.
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return false
+	}
+	return true
+}
+
+// cacheLookup tracks the use of the response cache for a ticket.
+type cacheLookup struct {
+	key string
+	// The stale entry to revalidate.
+	entry *specs.CacheEntry
+
+	mutex        sync.Mutex
+	requestTime  time.Time
+	responseTime time.Time
+	revalidated  *http.Response
+}
+
+// lookupCache serves the ticket with the response cache of the service
+// if the response is fresh. Otherwise, the conditional headers of the
+// stale response are added to the request. The lookup is nil if the
+// ticket doesn't use the cache.
+func (g *RestGuard) lookupCache(t *specs.RestTicket) (*cacheLookup, bool) {
+	t.CacheStatus = ""
+	if !t.Service.HasCache() || !specs.IsCacheableRequest(t.Request) {
+		return nil, false
+	}
+
+	ans := &cacheLookup{
+		key: ticketCacheKey(t),
+	}
+	t.CacheStatus = specs.CacheStatusMiss
+
+	e, err := t.Service.GetCache().Get(ans.key)
+	if err != nil || e == nil || !e.MatchVary(t.Request) {
+		return ans, false
+	}
+
+	now := time.Now()
+	if e.IsFresh(t.Request, now) {
+		t.Response = e.GetResponse(t.Request, now)
+		t.CacheStatus = specs.CacheStatusHit
+		g.Metrics.ObserveCache(t.Service.GetName(), specs.CacheStatusHit)
+		return ans, true
+	}
+
+	// The conditional headers of the caller are preserved.
+	if e.HasValidators() && t.Request.Header.Get("If-None-Match") == "" &&
+		t.Request.Header.Get("If-Modified-Since") == "" {
+		e.SetConditionalHeaders(t.Request)
+		ans.entry = e
+	}
+
+	return ans, false
+}
+
+// onResponse replaces the 304 response of a revalidation with the
+// response of the cache updated.
+func (l *cacheLookup) onResponse(s *specs.RestService, req *http.Request,
+	reqTime time.Time, resp *http.Response, err error) (*http.Response, error) {
+	if l == nil || err != nil {
+		return resp, err
+	}
+
+	respTime := time.Now()
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.requestTime = reqTime
+	l.responseTime = respTime
+
+	if l.entry == nil || resp.StatusCode != http.StatusNotModified {
+		return resp, err
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	e := l.entry.Revalidate(resp, reqTime, respTime)
+	// Ignoring error. The response is valid also without the store.
+	s.GetCache().Set(e)
+	l.revalidated = e.GetResponse(req, respTime)
+	return l.revalidated, nil
+}
+
+// readCloser reads the data already consumed before the body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// updateCache stores the response of the ticket in the cache of the
+// service or invalidates the response of the path after an unsafe
+// method.
+func (g *RestGuard) updateCache(t *specs.RestTicket, l *cacheLookup) {
+	if !t.Service.HasCache() || t.Response == nil {
+		return
+	}
+	c := t.Service.GetCache()
+
+	if isUnsafeMethod(t.Request.Method) {
+		if t.Response.StatusCode < 400 {
+			c.Delete(ticketCacheKey(t))
+		}
+		return
+	}
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	revalidated := l.revalidated
+	reqTime, respTime := l.requestTime, l.responseTime
+	l.mutex.Unlock()
+
+	if revalidated != nil && t.Response == revalidated {
+		t.CacheStatus = specs.CacheStatusRevalidated
+		g.Metrics.ObserveCache(t.Service.GetName(), specs.CacheStatusRevalidated)
+		return
+	}
+	g.Metrics.ObserveCache(t.Service.GetName(), specs.CacheStatusMiss)
+
+	resp := t.Response
+	if !specs.IsCacheableResponse(t.Request, resp) {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, specs.CacheMaxBodySize+1))
+	if err != nil || int64(len(data)) > specs.CacheMaxBodySize {
+		// The caller reads the body as received.
+		resp.Body = &readCloser{
+			Reader: io.MultiReader(bytes.NewReader(data), resp.Body),
+			Closer: resp.Body,
+		}
+		return
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	e := specs.NewCacheEntry(l.key, t.Request, resp, data, reqTime, respTime)
+	// Ignoring error. The cache is an optimization.
+	c.Set(e)
+}
@@ -0,0 +1,300 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ConfigFormatYaml = "yaml"
+	ConfigFormatJson = "json"
+)
+
+// RestGuardDocument describes the content of a configuration file:
+//
+//	config:
+//	  user_agent: "my-agent"
+//	  reqs_timeout: 60
+//	services:
+//	  mirrors:
+//	    retries: 2
+//	    retry_interval_ms: 100
+//	    options:
+//	      rate_limiter: "100/s"
+//	    nodes:
+//	      - name: mirror1
+//	        base_url: mirror1.example.com
+//	        ssl: true
+//	        rate_limit: "3000/m burst=50"
+type RestGuardDocument struct {
+	Config   *specs.RestGuardConfig        `json:"config,omitempty" yaml:"config,omitempty"`
+	Services map[string]*specs.RestService `json:"services,omitempty" yaml:"services,omitempty"`
+}
+
+// NewRestGuardFromFile creates a RestGuard from a YAML or JSON file.
+// The format is detected from the file extension, YAML is the default.
+func NewRestGuardFromFile(file string) (*RestGuard, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("error on open file %s: %s", file, err.Error())
+	}
+	defer fd.Close()
+
+	format := ConfigFormatYaml
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		format = ConfigFormatJson
+	}
+
+	ans, err := LoadConfig(fd, format)
+	if err != nil {
+		return nil, fmt.Errorf("error on load file %s: %s", file, err.Error())
+	}
+	return ans, nil
+}
+
+// LoadConfig parses the document in input and returns the RestGuard
+// with all the services and nodes defined.
+func LoadConfig(r io.Reader, format string) (*RestGuard, error) {
+	doc, err := ParseConfig(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	ans, err := NewRestGuard(doc.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, s := range doc.Services {
+		ans.AddService(name, s)
+	}
+
+	return ans, nil
+}
+
+// ParseConfig parses and validates the document in input. The services
+// are initialized with the defaults of specs.NewRestService. The unknown
+// fields are rejected.
+func ParseConfig(r io.Reader, format string) (*RestGuardDocument, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &RestGuardDocument{
+		Config:   specs.NewConfig(),
+		Services: make(map[string]*specs.RestService, 0),
+	}
+
+	switch strings.ToLower(format) {
+	case ConfigFormatYaml, "yml":
+		raw := struct {
+			Config   *specs.RestGuardConfig `yaml:"config,omitempty"`
+			Services map[string]yaml.Node   `yaml:"services,omitempty"`
+		}{
+			Config: doc.Config,
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&raw); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("error on parse yaml: %s", err.Error())
+		}
+		for name, node := range raw.Services {
+			s := specs.NewRestService(name)
+			// yaml.Node.Decode doesn't check the known fields.
+			b, err := yaml.Marshal(&node)
+			if err != nil {
+				return nil, fmt.Errorf("service %s: %s", name, err.Error())
+			}
+			dec := yaml.NewDecoder(bytes.NewReader(b))
+			dec.KnownFields(true)
+			if err := dec.Decode(s); err != nil {
+				return nil, fmt.Errorf("service %s: %s", name, err.Error())
+			}
+			doc.Services[name] = s
+		}
+
+	case ConfigFormatJson:
+		raw := struct {
+			Config   *specs.RestGuardConfig     `json:"config,omitempty"`
+			Services map[string]json.RawMessage `json:"services,omitempty"`
+		}{
+			Config: doc.Config,
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("error on parse json: %s", err.Error())
+		}
+		for name, msg := range raw.Services {
+			s := specs.NewRestService(name)
+			dec := json.NewDecoder(bytes.NewReader(msg))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(s); err != nil {
+				return nil, fmt.Errorf("service %s: %s", name, err.Error())
+			}
+			doc.Services[name] = s
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid config format %s", format)
+	}
+
+	for name, s := range doc.Services {
+		if err := setupService(name, s); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+// setupService validates the service and creates the
+// objects defined by the options.
+func setupService(name string, s *specs.RestService) error {
+	if s.Name == "" {
+		s.Name = name
+	} else if s.Name != name {
+		return fmt.Errorf("service %s: name %s doesn't match the service key",
+			name, s.Name)
+	}
+
+	if s.Options == nil {
+		s.Options = make(map[string]string, 0)
+	}
+
+	if s.Retries < 0 {
+		return fmt.Errorf("service %s: invalid retries %d", name, s.Retries)
+	}
+	if s.RetryIntervalMs < 0 {
+		return fmt.Errorf("service %s: invalid retry_interval_ms %d",
+			name, s.RetryIntervalMs)
+	}
+
+	if len(s.Nodes) == 0 {
+		return fmt.Errorf("service %s: no nodes defined", name)
+	}
+
+	names := make(map[string]bool, 0)
+	for idx, n := range s.Nodes {
+		if n == nil {
+			return fmt.Errorf("service %s: node #%d is empty", name, idx)
+		}
+		if n.Name == "" {
+			return fmt.Errorf("service %s: node #%d without name", name, idx)
+		}
+		if _, dup := names[n.Name]; dup {
+			return fmt.Errorf("service %s, node %s: duplicated node name",
+				name, n.Name)
+		}
+		names[n.Name] = true
+
+		n.BaseUrl = strings.TrimSuffix(n.BaseUrl, "/")
+		if n.BaseUrl == "" {
+			return fmt.Errorf("service %s, node %s: base_url is required",
+				name, n.Name)
+		}
+		if n.Weight < 0 {
+			return fmt.Errorf("service %s, node %s: invalid weight %d",
+				name, n.Name, n.Weight)
+		}
+		if err := n.SetRateLimiter(); err != nil {
+			return fmt.Errorf("service %s, node %s: %s", name, n.Name, err.Error())
+		}
+		if err := n.SetAuthenticator(); err != nil {
+			return fmt.Errorf("service %s, node %s: %s", name, n.Name, err.Error())
+		}
+		if n.TLS != nil {
+			if _, err := n.TLS.NewConfig(); err != nil {
+				return fmt.Errorf("service %s, node %s: %s", name, n.Name, err.Error())
+			}
+		}
+	}
+
+	if s.HasOption(specs.ServiceRateLimiter) || s.HasOption(specs.ServiceRateLimiterMode) {
+		if err := s.SetRateLimiter(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.Backoff != "" || s.HasOption(specs.ServiceBackoff) {
+		if err := s.SetBackoff(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.BreakerFailureThreshold != 0 || s.HasOption(specs.ServiceBreakerThreshold) {
+		if err := s.SetCircuitBreaker(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.RetryAfterMode != "" || s.HasOption(specs.ServiceRetryAfterMode) {
+		if err := s.SetRetryAfter(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.RetryPolicy != "" || s.HasOption(specs.ServiceRetryPolicy) ||
+		s.HasOption(specs.ServiceIdempotencyKey) {
+		if err := s.SetRetryPolicy(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.HedgeDelayMs != 0 || s.HedgePercentile != 0 || s.HedgeMaxInFlight != 0 ||
+		s.HasOption(specs.ServiceHedgeDelayMs) ||
+		s.HasOption(specs.ServiceHedgePercentile) ||
+		s.HasOption(specs.ServiceHedgeMaxInFlight) {
+		if err := s.SetHedging(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.BulkheadMaxConcurrent != 0 || s.HasOption(specs.ServiceBulkheadMaxConcurrent) {
+		if err := s.SetBulkhead(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.Cache != "" || s.HasOption(specs.ServiceCache) {
+		if err := s.SetCache(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.Selector != "" || s.HasOption(specs.ServiceSelector) {
+		if err := s.SetSelector(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if err := s.SetAuthenticator(); err != nil {
+		return fmt.Errorf("service %s: %s", name, err.Error())
+	}
+
+	if s.TLS != nil {
+		if _, err := s.TLS.NewConfig(); err != nil {
+			return fmt.Errorf("service %s: %s", name, err.Error())
+		}
+	}
+
+	if s.HealthCheck != nil && s.HealthCheck.Path == "" {
+		return fmt.Errorf("service %s: health_check without path", name)
+	}
+
+	return nil
+}
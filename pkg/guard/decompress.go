@@ -0,0 +1,182 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// DecompressAuto selects the format by the Content-Encoding, the
+	// Content-Type or the extension of the artefact received.
+	DecompressAuto = "auto"
+
+	CompressionGzip  = "gzip"
+	CompressionXz    = "xz"
+	CompressionZstd  = "zstd"
+	CompressionBzip2 = "bzip2"
+)
+
+var (
+	compressionExtensions = map[string]string{
+		".gz":   CompressionGzip,
+		".xz":   CompressionXz,
+		".zst":  CompressionZstd,
+		".zstd": CompressionZstd,
+		".bz2":  CompressionBzip2,
+	}
+
+	compressionTypes = map[string]string{
+		"application/gzip":    CompressionGzip,
+		"application/x-gzip":  CompressionGzip,
+		"application/x-xz":    CompressionXz,
+		"application/zstd":    CompressionZstd,
+		"application/x-bzip2": CompressionBzip2,
+		"application/x-bzip":  CompressionBzip2,
+	}
+
+	compressionEncodings = map[string]string{
+		"gzip":   CompressionGzip,
+		"x-gzip": CompressionGzip,
+		"zstd":   CompressionZstd,
+	}
+)
+
+func isValidDecompress(mode string) bool {
+	switch mode {
+	case "", DecompressAuto, CompressionGzip, CompressionXz,
+		CompressionZstd, CompressionBzip2:
+		return true
+	}
+	return false
+}
+
+// detectCompression returns the format of the response body with
+// the decompression mode in input or an empty string if the body
+// is not compressed.
+func detectCompression(mode string, resp *http.Response) string {
+	if mode != DecompressAuto {
+		return mode
+	}
+
+	v := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if format, ok := compressionEncodings[v]; ok {
+		return format
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		if format, ok := compressionTypes[mediaType]; ok {
+			return format
+		}
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		ext := strings.ToLower(path.Ext(resp.Request.URL.Path))
+		if format, ok := compressionExtensions[ext]; ok {
+			return format
+		}
+	}
+
+	return ""
+}
+
+func newDecompressReader(format string, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionXz:
+		reader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(reader), nil
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionBzip2:
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %s", format)
+}
+
+// streamHasher computes the size and the hashes of the stream
+// received before the decompression.
+type streamHasher struct {
+	md5      hash.Hash
+	sha512   hash.Hash
+	blake2b  hash.Hash
+	count    int64
+	progress *progressTracker
+}
+
+func newStreamHasher(progress *progressTracker) *streamHasher {
+	bhash, _ := blake2b.New512([]byte{})
+	return &streamHasher{
+		md5:      md5.New(),
+		sha512:   sha512.New(),
+		blake2b:  bhash,
+		progress: progress,
+	}
+}
+
+func (h *streamHasher) Write(p []byte) (int, error) {
+	h.md5.Write(p)
+	h.sha512.Write(p)
+	h.blake2b.Write(p)
+	h.count += int64(len(p))
+	h.progress.update("", h.count)
+	return len(p), nil
+}
+
+func (h *streamHasher) artefact() *specs.RestArtefact {
+	return &specs.RestArtefact{
+		Size:    h.count,
+		Md5:     fmt.Sprintf("%x", h.md5.Sum(nil)),
+		Sha512:  fmt.Sprintf("%x", h.sha512.Sum(nil)),
+		Blake2b: fmt.Sprintf("%x", h.blake2b.Sum(nil)),
+	}
+}
+
+// decompressBody writes the body decompressed in the writer and
+// hashes the body as received in a single pass.
+func decompressBody(format string, body io.Reader, w io.Writer,
+	progress *progressTracker) (*streamHasher, error) {
+	hasher := newStreamHasher(progress)
+	src := io.TeeReader(body, hasher)
+
+	reader, err := newDecompressReader(format, src)
+	if err != nil {
+		return hasher, err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(w, reader)
+	if err != nil {
+		return hasher, err
+	}
+
+	// Hash the bytes after the end of the compressed stream.
+	_, err = io.Copy(io.Discard, src)
+	return hasher, err
+}
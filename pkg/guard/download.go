@@ -5,12 +5,18 @@ See AUTHORS and LICENSE for the license details and contributors.
 package guard
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha512"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
+	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/geaaru/rest-guard/pkg/specs"
 
@@ -24,7 +30,8 @@ type ArtefactWriter struct {
 	md5     hash.Hash
 	path    string
 
-	count int64
+	count    int64
+	progress *progressTracker
 }
 
 func NewArtefactWriter(file string) (*ArtefactWriter, error) {
@@ -33,6 +40,21 @@ func NewArtefactWriter(file string) (*ArtefactWriter, error) {
 		return nil, fmt.Errorf("error on create file %s: %s",
 			file, err.Error())
 	}
+	return newArtefactWriter(fd, file), nil
+}
+
+// OpenArtefactWriter opens the file without truncate it. The writer
+// must be positioned with Resume or Reset before writing.
+func OpenArtefactWriter(file string) (*ArtefactWriter, error) {
+	fd, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error on open file %s: %s",
+			file, err.Error())
+	}
+	return newArtefactWriter(fd, file), nil
+}
+
+func newArtefactWriter(fd *os.File, file string) *ArtefactWriter {
 	bhash, _ := blake2b.New512([]byte{})
 
 	return &ArtefactWriter{
@@ -43,7 +65,7 @@ func NewArtefactWriter(file string) (*ArtefactWriter, error) {
 		blake2b: bhash,
 
 		count: 0,
-	}, nil
+	}
 }
 
 func (a *ArtefactWriter) Write(p []byte) (int, error) {
@@ -55,6 +77,7 @@ func (a *ArtefactWriter) Write(p []byte) (int, error) {
 
 	// Increment byte counter
 	a.count += int64(len(p))
+	a.progress.update("", a.count)
 
 	// Update md5
 	_, err = a.md5.Write(p)
@@ -81,6 +104,54 @@ func (a *ArtefactWriter) Close() error {
 	return a.fd.Close()
 }
 
+// Reset truncates the file and resets the hashes.
+func (a *ArtefactWriter) Reset() error {
+	return a.Resume(0)
+}
+
+// Resume truncates the file to the offset in input, feeds the hashes
+// with the bytes already written and moves the writer at the end
+// of the file.
+func (a *ArtefactWriter) Resume(offset int64) error {
+	err := a.fd.Truncate(offset)
+	if err != nil {
+		return err
+	}
+
+	a.md5.Reset()
+	a.sha512.Reset()
+	a.blake2b.Reset()
+	a.count = 0
+
+	_, err = a.fd.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	if offset > 0 {
+		hashes := io.MultiWriter(a.md5, a.sha512, a.blake2b)
+		n, err := io.Copy(hashes, io.LimitReader(a.fd, offset))
+		if err != nil {
+			return err
+		}
+		if n != offset {
+			return fmt.Errorf("unexpected size %d of file %s", n, a.path)
+		}
+		a.count = offset
+	}
+
+	return nil
+}
+
+// GetSize returns the size of the file on disk.
+func (a *ArtefactWriter) GetSize() (int64, error) {
+	info, err := a.fd.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
 func (a *ArtefactWriter) MD5() string {
 	return fmt.Sprintf("%x", a.md5.Sum(nil))
 }
@@ -96,44 +167,338 @@ func (a *ArtefactWriter) Blake2b() string {
 func (a *ArtefactWriter) GetPath() string { return a.path }
 func (a *ArtefactWriter) GetCount() int64 { return a.count }
 
+// DownloadOptions customizes the behaviour of DoDownloadWithOptions.
+type DownloadOptions struct {
+	// Resume enables the download through the <artefact>.part file.
+	// The file is preserved on failure and the next attempts restart
+	// from the bytes already downloaded with HTTP Range requests.
+	Resume bool
+
+	// Segments enables the download of the artefact in parallel
+	// segments from the active nodes when the nodes support the
+	// ranges. The segments are at least SegmentMinSize bytes
+	// (default DefaultSegmentMinSize). Not used with Resume
+	// and Decompress.
+	Segments       int
+	SegmentMinSize int64
+
+	// ProgressCb receives the progress of the download every
+	// ProgressInterval (default DefaultProgressInterval), on the
+	// retries and at the end of the download.
+	ProgressCb       func(p DownloadProgress)
+	ProgressInterval time.Duration
+
+	// Decompress writes the artefact decompressed on the fly. The value
+	// is the format (gzip, xz, zstd, bzip2) or DecompressAuto to select
+	// it from the response. The artefact returned contains the hashes
+	// of the stream received in the Compressed field. Not supported
+	// with Resume.
+	Decompress string
+
+	// Expected contains the size and the hashes to verify after the
+	// download. Only the fields not empty are checked. The Compressed
+	// field is checked with the stream received. On mismatch
+	// the temporary file is removed and the download is retried on
+	// the next node.
+	Expected *specs.RestArtefact
+}
+
 func (g *RestGuard) DoDownload(t *specs.RestTicket, artefactPath string) (*specs.RestArtefact, error) {
-	artefactWriter, err := NewArtefactWriter(artefactPath)
+	return g.DoDownloadContext(context.Background(), t, artefactPath)
+}
+
+// DoDownloadContext downloads the artefact and stops the retries
+// loop or the body transfer when the context is done.
+func (g *RestGuard) DoDownloadContext(ctx context.Context, t *specs.RestTicket, artefactPath string) (*specs.RestArtefact, error) {
+	return g.DoDownloadWithOptions(ctx, t, artefactPath, nil)
+}
+
+func (g *RestGuard) DoDownloadWithOptions(ctx context.Context, t *specs.RestTicket,
+	artefactPath string, opts *DownloadOptions) (*specs.RestArtefact, error) {
+
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+	if !isValidDecompress(opts.Decompress) {
+		return nil, fmt.Errorf("unsupported compression %s", opts.Decompress)
+	}
+	if opts.Resume && opts.Decompress != "" {
+		return nil, errors.New("The decompression is not supported with resume.")
+	}
+
+	var mismatchErr *ChecksumMismatchError
+	progress := newProgressTracker(t, artefactPath, opts)
+
+	for {
+		var artefact *specs.RestArtefact
+		var err error
+
+		// The artefact is written on a temporary file and renamed
+		// after the checks. The resumable downloads use the part file.
+		workPath := artefactPath + partSuffix
+		if !opts.Resume {
+			workPath, err = createTempPath(artefactPath)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if opts.Resume {
+			artefact, err = g.doDownloadResume(ctx, t, artefactPath, progress)
+		} else if opts.Segments > 1 && opts.Decompress == "" {
+			artefact, err = g.doDownloadSegmented(ctx, t, workPath, opts, progress)
+		} else {
+			artefact, err = g.doDownload(ctx, t, workPath, opts.Decompress, progress)
+		}
+		if t.Response != nil {
+			// Release the connection and the slot of the bulkhead.
+			t.Response.Body.Close()
+		}
+		if err != nil {
+			if !opts.Resume {
+				os.Remove(workPath)
+			}
+			return nil, err
+		}
+
+		var mismatch *ChecksumMismatch
+		if opts.Expected != nil {
+			mismatch = verifyArtefact(opts.Expected, artefact)
+		}
+		if mismatch == nil {
+			err = commitArtefact(workPath, artefactPath)
+			if err != nil {
+				if !opts.Resume {
+					os.Remove(workPath)
+				}
+				return nil, err
+			}
+			os.Remove(artefactPath + partInfoSuffix)
+			artefact.Path = artefactPath
+			progress.done(artefact)
+			return artefact, nil
+		}
+
+		// The node serves a corrupted or stale artefact.
+		os.Remove(workPath)
+		os.Remove(artefactPath + partInfoSuffix)
+		if b := t.Service.GetBreaker(t.Node); b != nil {
+			b.OnFailure()
+		}
+
+		if mismatchErr == nil {
+			mismatchErr = &ChecksumMismatchError{
+				Path:     artefactPath,
+				Expected: opts.Expected,
+			}
+		}
+		mismatch.Node = t.Node.Name
+		mismatchErr.Mismatches = append(mismatchErr.Mismatches, *mismatch)
+
+		// The mismatches don't use the retries of the service: all
+		// the nodes are tried.
+		if untriedNode(t) == nil {
+			return nil, mismatchErr
+		}
+
+		err = g.nextDownloadNode(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		progress.retry(mismatch.Node, len(mismatchErr.Mismatches))
+	}
+}
+
+// untriedNode returns an active node of the service that is not
+// failed and is not the node of the ticket, or nil.
+func untriedNode(t *specs.RestTicket) *specs.RestNode {
+	nodes, _ := getActiveNodes(t.Service)
+	for _, n := range nodes {
+		if !n.Equal(t.Node) && !t.FailedNodes.HasNode(n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// createTempPath returns the path of a new temporary file in
+// the directory of the artefact. Like os.Create, the file is
+// created with the mode 0666 before the umask.
+func createTempPath(artefactPath string) (string, error) {
+	dir, base := filepath.Split(artefactPath)
+	for i := 0; i < 10000; i++ {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d.tmp", base, rand.Uint32()))
+		fd, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error on create temporary file for %s: %s",
+				artefactPath, err.Error())
+		}
+		fd.Close()
+		return name, nil
+	}
+	return "", fmt.Errorf("error on create temporary file for %s: too many attempts",
+		artefactPath)
+}
+
+// commitArtefact flushes the temporary file on disk and renames it
+// atomically over the artefact. The permissions of the existing
+// artefact are preserved.
+func commitArtefact(tmpPath, artefactPath string) error {
+	fd, err := os.OpenFile(tmpPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("error on open file %s: %s", tmpPath, err.Error())
+	}
+	err = fd.Sync()
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error on sync file %s: %s", tmpPath, err.Error())
+	}
+
+	if info, err := os.Stat(artefactPath); err == nil {
+		err = os.Chmod(tmpPath, info.Mode().Perm())
+		if err != nil {
+			return fmt.Errorf("error on chmod file %s: %s", tmpPath, err.Error())
+		}
+	}
+
+	err = os.Rename(tmpPath, artefactPath)
+	if err != nil {
+		return fmt.Errorf("error on rename file %s: %s", tmpPath, err.Error())
+	}
+
+	// Persist the rename.
+	dir, err := os.Open(filepath.Dir(artefactPath))
+	if err != nil {
+		return fmt.Errorf("error on open directory of %s: %s", artefactPath, err.Error())
+	}
+	defer dir.Close()
+	err = dir.Sync()
+	if err != nil {
+		return fmt.Errorf("error on sync directory of %s: %s", artefactPath, err.Error())
+	}
+
+	return nil
+}
+
+// verifyArtefact compares the not empty fields of the expected
+// artefact with the artefact downloaded.
+func verifyArtefact(expected, artefact *specs.RestArtefact) *ChecksumMismatch {
+	if expected.Size > 0 && expected.Size != artefact.Size {
+		return &ChecksumMismatch{
+			Field:    "size",
+			Expected: fmt.Sprintf("%d", expected.Size),
+			Got:      fmt.Sprintf("%d", artefact.Size),
+		}
+	}
+
+	hashes := []struct {
+		field    string
+		expected string
+		got      string
+	}{
+		{"md5", expected.Md5, artefact.Md5},
+		{"sha512", expected.Sha512, artefact.Sha512},
+		{"blake2b", expected.Blake2b, artefact.Blake2b},
+	}
+
+	for _, h := range hashes {
+		if h.expected != "" && !strings.EqualFold(h.expected, h.got) {
+			return &ChecksumMismatch{
+				Field:    h.field,
+				Expected: h.expected,
+				Got:      h.got,
+			}
+		}
+	}
+
+	if expected.Compressed != nil {
+		// Without decompression the stream received is the artefact.
+		received := artefact
+		if artefact.Compressed != nil {
+			received = artefact.Compressed
+		}
+		if m := verifyArtefact(expected.Compressed, received); m != nil {
+			m.Field = "compressed " + m.Field
+			return m
+		}
+	}
+
+	return nil
+}
+
+func (g *RestGuard) doDownload(ctx context.Context, t *specs.RestTicket,
+	file, decompress string, progress *progressTracker) (*specs.RestArtefact, error) {
+	artefactWriter, err := NewArtefactWriter(file)
 	if err != nil {
 		return nil, err
 	}
 	defer artefactWriter.Close()
 
-	err = g.doClient(g.Client, t)
+	if decompress != "" && t.Request != nil {
+		// Receive the stream as published by the node.
+		t.Request.Header.Set("Accept-Encoding", "identity")
+	}
+
+	retries := t.Retries
+	err = g.doClient(ctx, g.Client, t, false)
+	progress.retriesSince(t, retries)
 	if err != nil {
-		defer os.Remove(artefactPath)
+		defer os.Remove(file)
 		return nil, err
 	}
 
 	if t.Response == nil {
-		defer os.Remove(artefactPath)
+		defer os.Remove(file)
 		return nil, fmt.Errorf("invalid response received")
 	}
 
 	if t.Response.StatusCode != 200 {
-		defer os.Remove(artefactPath)
+		defer os.Remove(file)
 		return nil, fmt.Errorf("received response code %d", t.Response.StatusCode)
 	}
 
 	// Read response and write file
-	_, err = io.Copy(artefactWriter, t.Response.Body)
+	progress.begin(t.Node.Name, 0, t.Response.ContentLength)
+	format := detectCompression(decompress, t.Response)
+	copyStart := time.Now()
+	var n int64
+	var hasher *streamHasher
+	if format == "" {
+		artefactWriter.progress = progress
+		n, err = io.Copy(artefactWriter, t.Response.Body)
+	} else {
+		hasher, err = decompressBody(format, t.Response.Body, artefactWriter, progress)
+		n = hasher.count
+	}
+	g.Metrics.ObserveDownload(t.Service.GetName(), t.Node.Name, n, time.Since(copyStart))
 	if err != nil {
-		defer os.Remove(artefactPath)
+		defer os.Remove(file)
+		if ctx.Err() != nil {
+			return nil, newInterruptedError(t, PhaseDownload, ctx.Err())
+		}
+		if format != "" {
+			return nil, fmt.Errorf("error on decompress %s to file %s: %s",
+				format, file, err.Error())
+		}
 		return nil, fmt.Errorf("error on writing file %s: %s",
-			artefactPath, err.Error())
+			file, err.Error())
 	}
 
 	ans := &specs.RestArtefact{
-		Path:    artefactPath,
+		Path:    file,
 		Size:    artefactWriter.GetCount(),
 		Md5:     artefactWriter.MD5(),
 		Sha512:  artefactWriter.Sha512(),
 		Blake2b: artefactWriter.Blake2b(),
 	}
+	if hasher != nil {
+		ans.Compressed = hasher.artefact()
+	}
 
 	return ans, nil
 }
@@ -0,0 +1,108 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+const (
+	PhaseBulkhead    = "bulkhead"
+	PhaseRateLimiter = "rate-limiter"
+	PhaseRetryWait   = "retry-wait"
+	PhaseRequest     = "request"
+	PhaseDownload    = "download"
+)
+
+// InterruptedError is returned when the context of a ticket
+// is cancelled or expired while the guard is processing it.
+type InterruptedError struct {
+	// The ticket id
+	TicketId string
+	// The attempt interrupted (starting from 1)
+	Attempt int
+	// The node used by the interrupted attempt
+	Node string
+	// The phase where the interruption is been detected
+	Phase string
+	// The context error
+	Err error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("ticket %s interrupted on attempt %d (node %s, phase %s): %s",
+		e.TicketId, e.Attempt, e.Node, e.Phase, e.Err.Error())
+}
+
+func (e *InterruptedError) Unwrap() error { return e.Err }
+
+// ChecksumMismatch describes the first field of the artefact
+// that doesn't match with the expected value.
+type ChecksumMismatch struct {
+	Node     string
+	Field    string
+	Expected string
+	Got      string
+}
+
+// ChecksumMismatchError is returned by the download when the artefact
+// doesn't match with the expected artefact on all the nodes tried.
+type ChecksumMismatchError struct {
+	Path       string
+	Expected   *specs.RestArtefact
+	Mismatches []ChecksumMismatch
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	nodes := []string{}
+	for _, m := range e.Mismatches {
+		nodes = append(nodes, fmt.Sprintf("%s (%s expected %s, got %s)",
+			m.Node, m.Field, m.Expected, m.Got))
+	}
+	return fmt.Sprintf("checksum mismatch for %s on nodes: %s",
+		e.Path, strings.Join(nodes, ", "))
+}
+
+// RetryAfterError is returned when a node requests with the
+// Retry-After header an interval greater than the max configured.
+type RetryAfterError struct {
+	TicketId string
+	Node     string
+	Delay    time.Duration
+	Max      time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("ticket %s: node %s requested a retry after %s (max %s)",
+		e.TicketId, e.Node, e.Delay, e.Max)
+}
+
+// isConnectError returns true if the error is received before that
+// the connection with the node is established. In this case the
+// request is not been sent.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// isTimeoutError returns true if the request is failed for a timeout.
+func isTimeoutError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
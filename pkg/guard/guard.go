@@ -9,8 +9,11 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/geaaru/rest-guard/pkg/specs"
@@ -22,6 +25,17 @@ type RestGuard struct {
 
 	Services map[string]*specs.RestService `json:"services" yaml:"services"`
 	RetryCb  func(guard *RestGuard, t *specs.RestTicket) (*specs.RestNode, error)
+
+	Middlewares []specs.Middleware `json:"-" yaml:"-"`
+	// Metrics of the services. It could be exposed as HTTP handler.
+	Metrics *Metrics `json:"-" yaml:"-"`
+
+	healthChecker *HealthChecker
+	healthMutex   sync.Mutex
+
+	// The transports of the TLS profiles.
+	transports      map[string]*http.Transport
+	transportsMutex sync.Mutex
 }
 
 func NewRestGuard(cfg *specs.RestGuardConfig) (*RestGuard, error) {
@@ -54,6 +68,9 @@ func NewRestGuard(cfg *specs.RestGuardConfig) (*RestGuard, error) {
 		UserAgent: cfg.UserAgent,
 		RetryCb:   nil,
 		Services:  make(map[string]*specs.RestService, 0),
+		Metrics:   NewMetrics(),
+
+		transports: make(map[string]*http.Transport, 0),
 	}
 
 	ans.Client = &http.Client{
@@ -90,6 +107,18 @@ func (g *RestGuard) GetService(srv string) (*specs.RestService, error) {
 }
 
 func (g *RestGuard) CreateRequest(t *specs.RestTicket, method, path string) (*http.Request, error) {
+	return g.CreateRequestContext(context.Background(), t, method, path)
+}
+
+func (g *RestGuard) CreateRequestContext(ctx context.Context, t *specs.RestTicket, method, path string) (*http.Request, error) {
+	return g.createRequest(ctx, t, method, path, true)
+}
+
+// createRequest creates the request of the ticket. With deferNode
+// and a fresh response in the cache of the service the node is not
+// selected and the request has only the path. The node is selected
+// on execution if the response cache can't serve the ticket.
+func (g *RestGuard) createRequest(ctx context.Context, t *specs.RestTicket, method, path string, deferNode bool) (*http.Request, error) {
 
 	if t.Service == nil {
 		return nil, errors.New("The ticket is without service.")
@@ -103,16 +132,21 @@ func (g *RestGuard) CreateRequest(t *specs.RestTicket, method, path string) (*ht
 		return nil, errors.New("Service without response validator")
 	}
 
-	activeNodes := []*specs.RestNode{}
-	for idx := range t.Service.Nodes {
-		if t.Service.Nodes[idx].Disable {
-			continue
+	var rn *specs.RestNode
+	var reqUrl string
+	var err error
+	if deferNode && t.Node == nil && method == http.MethodGet &&
+		isCachedFresh(t.Service, path) {
+		reqUrl = path
+		if !strings.HasPrefix(path, "/") {
+			reqUrl = "/" + path
 		}
-		activeNodes = append(activeNodes, t.Service.Nodes[idx])
-	}
-
-	if len(activeNodes) == 0 {
-		return nil, errors.New("The service is without active nodes.")
+	} else {
+		rn, err = selectNode(t)
+		if err != nil {
+			return nil, err
+		}
+		reqUrl = nodeUrl(rn, path)
 	}
 
 	if t.Request != nil {
@@ -120,22 +154,8 @@ func (g *RestGuard) CreateRequest(t *specs.RestTicket, method, path string) (*ht
 	}
 	t.Path = path
 
-	var rn *specs.RestNode
-	if t.Node == nil {
-		rn = activeNodes[t.Retries%len(activeNodes)]
-		t.Node = rn
-	} else {
-		rn = t.Node
-	}
-
-	url := rn.GetUrlPrefix()
-	if strings.HasPrefix(path, "/") {
-		url += path
-	} else {
-		url += "/" + path
-	}
-
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequestWithContext(g.withTokenClient(ctx, t.Service),
+		method, reqUrl, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -144,9 +164,21 @@ func (g *RestGuard) CreateRequest(t *specs.RestTicket, method, path string) (*ht
 		req.Header.Add("User-Agent", g.GetUserAgent())
 	}
 
+	if rn != nil {
+		err = authenticate(t, rn, req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	t.Request = req
 
-	if t.RequestBodyCb != nil {
+	if t.HasBody() {
+		err = t.ApplyBody(req)
+		if err != nil {
+			return nil, err
+		}
+	} else if t.RequestBodyCb != nil {
 		hasBody, reader, err := t.RequestBodyCb(t)
 		if err != nil {
 			return nil, err
@@ -159,128 +191,732 @@ func (g *RestGuard) CreateRequest(t *specs.RestTicket, method, path string) (*ht
 	return req, nil
 }
 
-func (g *RestGuard) doClient(c *http.Client, t *specs.RestTicket) error {
+// selectNode sets the node of the ticket if not already present.
+func selectNode(t *specs.RestTicket) (*specs.RestNode, error) {
+	if t.Node != nil {
+		return t.Node, nil
+	}
+
+	activeNodes, coolingNode := getActiveNodes(t.Service)
+	if len(activeNodes) == 0 && coolingNode != nil {
+		// All nodes are cooling-down. The attempt waits the
+		// end of the cooldown of the node.
+		activeNodes = append(activeNodes, coolingNode)
+	}
+
+	if len(activeNodes) == 0 {
+		return nil, errors.New("The service is without active nodes.")
+	}
+
+	var rn *specs.RestNode
+	if t.Service.HasNodeSelector() {
+		candidates := activeNodes
+		if len(t.FailedNodes) > 0 {
+			// Prefer the nodes not yet failed for the ticket.
+			candidates = []*specs.RestNode{}
+			for _, n := range activeNodes {
+				if !t.FailedNodes.HasNode(n) {
+					candidates = append(candidates, n)
+				}
+			}
+			if len(candidates) == 0 {
+				candidates = activeNodes
+			}
+		}
+		rn = t.Service.GetNodeSelector().Select(t, candidates)
+	} else {
+		rn = activeNodes[t.Retries%len(activeNodes)]
+	}
+	t.Node = rn
+	if b := t.Service.GetBreaker(rn); b != nil {
+		b.Acquire()
+	}
+	return rn, nil
+}
+
+// bindNode selects the node of a ticket created without node and
+// updates the URL and the credentials of the request.
+func bindNode(t *specs.RestTicket) error {
+	rn, err := selectNode(t)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(nodeUrl(rn, t.Path))
+	if err != nil {
+		return err
+	}
+	// Preserve the query modified after the creation.
+	u.RawQuery = t.Request.URL.RawQuery
+	t.Request.URL = u
+	t.Request.Host = u.Host
+	return authenticate(t, rn, t.Request)
+}
+
+// renewRequest creates a new request for the node of the ticket
+// with the headers of the request in input sent to the node prevNode.
+func (g *RestGuard) renewRequest(ctx context.Context, t *specs.RestTicket,
+	currReq *http.Request, prevNode *specs.RestNode) error {
+	newReq, err := g.createRequest(ctx, t, currReq.Method, t.Path, false)
+	if err != nil {
+		return err
+	}
+	newReq.Header = currReq.Header.Clone()
+	if newReq.Body == nil && currReq.GetBody != nil {
+		// The tickets of the hedged requests are without body.
+		body, err := currReq.GetBody()
+		if err != nil {
+			return err
+		}
+		newReq.Body = body
+		newReq.GetBody = currReq.GetBody
+		newReq.ContentLength = currReq.ContentLength
+	}
+	// The credentials could be different between the nodes.
+	deauthenticate(t, prevNode, newReq)
+	return authenticate(t, t.Node, newReq)
+}
+
+// deauthenticate removes from the request the credentials set by
+// the authenticator of the node. The Authorization header is removed
+// for the authenticators that are not Deauthenticator.
+func deauthenticate(t *specs.RestTicket, n *specs.RestNode, req *http.Request) {
+	a := t.Service.GetAuthenticator(n)
+	if a == nil {
+		return
+	}
+	if d, ok := a.(specs.Deauthenticator); ok {
+		d.Deauthenticate(req)
+	} else {
+		req.Header.Del("Authorization")
+	}
+}
+
+// withTokenClient returns the context with the client of the token
+// requests of the authenticators. The client uses the TLS profile
+// of the service.
+func (g *RestGuard) withTokenClient(ctx context.Context, s *specs.RestService) context.Context {
+	c, err := g.nodeClient(g.Client, s, nil)
+	if err != nil {
+		return ctx
+	}
+	return specs.WithTokenClient(ctx, c)
+}
+
+// authenticate sets the credentials of the node or of the
+// service to the request.
+func authenticate(t *specs.RestTicket, n *specs.RestNode, req *http.Request) error {
+	a := t.Service.GetAuthenticator(n)
+	if a == nil {
+		return nil
+	}
+	err := a.Authenticate(req)
+	if err != nil {
+		return fmt.Errorf("error on authenticate request for node %s: %s",
+			n.Name, err.Error())
+	}
+	return nil
+}
+
+// getActiveNodes returns the nodes available for a new request and
+// the node cooling-down that is available first.
+func getActiveNodes(s *specs.RestService) ([]*specs.RestNode, *specs.RestNode) {
+	activeNodes := []*specs.RestNode{}
+	var coolingNode *specs.RestNode
+	for idx := range s.Nodes {
+		if !s.Nodes[idx].IsActive() {
+			continue
+		}
+		// Skip nodes with the circuit breaker open.
+		if b := s.GetBreaker(s.Nodes[idx]); b != nil && !b.Ready() {
+			continue
+		}
+		// Skip nodes cooling-down but track the first available.
+		if s.Nodes[idx].IsCoolingDown() {
+			if coolingNode == nil ||
+				s.Nodes[idx].GetCooldown() < coolingNode.GetCooldown() {
+				coolingNode = s.Nodes[idx]
+			}
+			continue
+		}
+		activeNodes = append(activeNodes, s.Nodes[idx])
+	}
+	return activeNodes, coolingNode
+}
+
+// nodeUrl returns the URL of the path on the node.
+func nodeUrl(n *specs.RestNode, path string) string {
+	url := n.GetUrlPrefix()
+	if strings.HasPrefix(path, "/") {
+		return url + path
+	}
+	return url + "/" + path
+}
+
+func newInterruptedError(t *specs.RestTicket, phase string, err error) error {
+	return newAttemptInterruptedError(t, t.Retries+1, t.Node, phase, err)
+}
+
+// newAttemptInterruptedError returns the InterruptedError of the input
+// attempt. It's used on the retry wait, where the ticket is already
+// prepared for the next attempt.
+func newAttemptInterruptedError(t *specs.RestTicket, attempt int,
+	n *specs.RestNode, phase string, err error) error {
+	node := ""
+	if n != nil {
+		node = n.Name
+	}
+	return &InterruptedError{
+		TicketId: t.Id,
+		Attempt:  attempt,
+		Node:     node,
+		Phase:    phase,
+		Err:      err,
+	}
+}
+
+// sleepContext waits for the input duration or until the context
+// is done. It returns the context error on cancellation.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rangeDownloadKey marks the context of the Range requests sent by
+// the resumable and the segmented downloads.
+type rangeDownloadKey struct{}
+
+func withRangeDownload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rangeDownloadKey{}, true)
+}
+
+func isRangeDownload(ctx context.Context) bool {
+	v, _ := ctx.Value(rangeDownloadKey{}).(bool)
+	return v
+}
+
+// validateResponse checks the response with the validator of the
+// service. The responses 206 and 416 of the Range requests of the
+// downloads are managed by the caller.
+func validateResponse(t *specs.RestTicket) (bool, error) {
+	if isRangeDownload(t.Request.Context()) &&
+		t.Request.Header.Get("Range") != "" &&
+		(t.Response.StatusCode == http.StatusPartialContent ||
+			t.Response.StatusCode == http.StatusRequestedRangeNotSatisfiable) {
+		return true, nil
+	}
+	return t.Service.RespValidatorCb(t)
+}
+
+// Use registers the middlewares executed for the tickets of all
+// the services. The middlewares must be registered before the
+// execution of the tickets.
+func (g *RestGuard) Use(mws ...specs.Middleware) {
+	g.Middlewares = append(g.Middlewares, mws...)
+}
+
+func (g *RestGuard) getMiddlewares(s *specs.RestService) []specs.Middleware {
+	ans := make([]specs.Middleware, 0, len(g.Middlewares)+len(s.Middlewares))
+	ans = append(ans, g.Middlewares...)
+	return append(ans, s.GetMiddlewares()...)
+}
+
+// doClient executes the ticket with the client in input. With
+// useCache the response cache of the service is used.
+func (g *RestGuard) doClient(ctx context.Context, c *http.Client, t *specs.RestTicket, useCache bool) error {
+	if t.Request == nil {
+		return errors.New("The ticket is without request.")
+	}
+	if t.Service == nil {
+		return errors.New("The ticket is without service.")
+	}
+
+	var lookup *cacheLookup
+	if useCache {
+		var hit bool
+		lookup, hit = g.lookupCache(t)
+		if hit {
+			return nil
+		}
+	}
+	if t.Node == nil {
+		err := bindNode(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	release, err := g.acquireBulkhead(ctx, t)
+	if err != nil {
+		return err
+	}
+	defer holdBulkhead(t, release)
+
+	mws := g.getMiddlewares(t.Service)
+	attempt := specs.ChainAttempt(func(t *specs.RestTicket, req *http.Request) (*http.Response, error) {
+		t.Request = req
+		nc, err := g.nodeClient(c, t.Service, t.Node)
+		if err != nil {
+			return nil, err
+		}
+		reqTime := time.Now()
+		resp, err := nc.Do(req)
+		return lookup.onResponse(t.Service, req, reqTime, resp, err)
+	}, mws...)
+
+	err = specs.ChainTicket(func(ctx context.Context, t *specs.RestTicket) error {
+		return g.doAttempts(ctx, attempt, mws, t)
+	}, mws...)(ctx, t)
+	if err == nil && useCache {
+		g.updateCache(t, lookup)
+	}
+	return err
+}
+
+// bulkheadSlotKey marks the context of the requests that share the
+// slot of the bulkhead already reserved by the caller.
+type bulkheadSlotKey struct{}
+
+func withBulkheadSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bulkheadSlotKey{}, true)
+}
+
+func hasBulkheadSlot(ctx context.Context) bool {
+	v, _ := ctx.Value(bulkheadSlotKey{}).(bool)
+	return v
+}
+
+// acquireBulkhead reserves a slot on the bulkhead of the service if
+// present. The function returned releases the slot.
+func (g *RestGuard) acquireBulkhead(ctx context.Context, t *specs.RestTicket) (func(), error) {
+	if !t.Service.HasBulkhead() || hasBulkheadSlot(ctx) {
+		return func() {}, nil
+	}
+	b := t.Service.GetBulkhead()
+	err := b.Acquire(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, newInterruptedError(t, PhaseBulkhead, ctx.Err())
+		}
+		g.Metrics.ObserveBulkheadRejection(t.Service.GetName())
+		return nil, err
+	}
+	return b.Release, nil
+}
+
+// releaseOnClose releases the slot of the bulkhead when the body
+// of the response is closed.
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// holdBulkhead keeps the slot of the bulkhead until the body of the
+// response of the ticket is closed. The slot is released immediately
+// without response.
+func holdBulkhead(t *specs.RestTicket, release func()) {
+	if !t.Service.HasBulkhead() || t.Response == nil || t.Response.Body == nil {
+		release()
+		return
+	}
+	t.Response.Body = &releaseOnClose{
+		ReadCloser: t.Response.Body,
+		release:    release,
+	}
+}
+
+// waitRateLimiter ensures the limits of the rate limiter of the
+// service and of the node in input if present.
+func (g *RestGuard) waitRateLimiter(ctx context.Context, t *specs.RestTicket, n *specs.RestNode) error {
+	if !t.Service.HasRateLimiter() && (n == nil || !n.HasRateLimiter()) {
+		return nil
+	}
+	// NOTE: Check if the wait lock requests for all services.
+	waitStart := time.Now()
+	var err error
+	if t.Service.HasRateLimiter() {
+		err = t.Service.GetRateLimiter().Wait(ctx)
+	}
+	if err == nil && n != nil && n.HasRateLimiter() {
+		err = n.GetRateLimiter().Wait(ctx)
+	}
+	g.Metrics.ObserveRateLimiterWait(t.Service.GetName(), time.Since(waitStart))
+	if err != nil {
+		if ctx.Err() != nil {
+			return newInterruptedError(t, PhaseRateLimiter, ctx.Err())
+		}
+		return fmt.Errorf("error on rate limiting: %s", err.Error())
+	}
+	return nil
+}
+
+// adaptRateLimiter updates the adaptive rate limiter of the
+// service with the result of an attempt.
+func (g *RestGuard) adaptRateLimiter(s *specs.RestService, resp *http.Response, err error) {
+	if !s.HasAdaptiveLimiter() {
+		return
+	}
+	a := s.GetAdaptiveLimiter()
+	if err != nil {
+		if !isTimeoutError(err) {
+			return
+		}
+		a.OnThrottle()
+	} else {
+		a.OnResponse(resp.StatusCode)
+	}
+	g.Metrics.ObserveRateLimit(s.GetName(), a.GetRate())
+}
+
+func (g *RestGuard) doAttempts(ctx context.Context, attempt specs.AttemptFunc,
+	mws []specs.Middleware, t *specs.RestTicket) error {
 	var ans error = nil
 
-	ctx := context.Background()
+	// Ensure that the request follows the context in input.
+	if t.Request.Context() != ctx {
+		t.Request = t.Request.WithContext(ctx)
+	}
+
+	if t.Service.IdempotencyKey && t.Request.Header.Get(specs.IdempotencyKeyHeader) == "" {
+		// The header is reused on all the attempts.
+		t.Request.Header.Set(specs.IdempotencyKeyHeader, t.Id)
+	}
 
-	handleRetry := func() error {
+	// The retryAfter interval is used in place of the backoff. With
+	// sameNode the retry is executed on the node that has failed.
+	handleRetry := func(retryAfter time.Duration, sameNode bool) error {
+		failedAttempt := t.Retries + 1
 		t.Retries++
 		currReq := t.Request
+		prevNode := t.Node
 		t.AddFail(t.Node)
-		if g.RetryCb != nil {
-			node, err := g.RetryCb(g, t)
-			if err != nil {
-				return err
+		if t.Retries > t.Service.Retries {
+			// No more attempts. The request is not renewed to
+			// avoid the selection of a new node.
+			return nil
+		}
+		g.Metrics.ObserveRetry(t.Service.GetName(), t.Node.Name)
+		if !sameNode {
+			if g.RetryCb != nil {
+				node, err := g.RetryCb(g, t)
+				if err != nil {
+					return err
+				}
+				t.Node = node
+			} else {
+				t.Node = nil
 			}
-			t.Node = node
-		} else {
-			t.Node = nil
 		}
-		newReq, err := g.CreateRequest(t, currReq.Method, t.Path)
+		err := g.renewRequest(ctx, t, currReq, prevNode)
 		if err != nil {
 			return err
 		}
-		newReq.Header = currReq.Header
 
-		if t.FailedNodes.HasNode(t.Node) && t.Service.RetryIntervalMs > 0 {
-			sleepms, err := time.ParseDuration(fmt.Sprintf(
+		var sleepms time.Duration
+		if retryAfter > 0 {
+			sleepms = retryAfter
+		} else if sameNode {
+			sleepms = 0
+		} else if t.Service.HasBackoffPolicy() {
+			// With a backoff policy the wait is applied on every retry.
+			sleepms = t.Service.GetBackoffPolicy().Next(t.Retries, t.LastInterval)
+		} else if t.FailedNodes.HasNode(t.Node) && t.Service.RetryIntervalMs > 0 {
+			sleepms, err = time.ParseDuration(fmt.Sprintf(
 				"%dms", t.Service.RetryIntervalMs))
 			if err != nil {
 				return err
 			}
-			time.Sleep(sleepms)
+		}
+
+		if sleepms > 0 {
+			t.LastInterval = sleepms
+			err = sleepContext(ctx, sleepms)
+			if err != nil {
+				return newAttemptInterruptedError(t, failedAttempt, prevNode,
+					PhaseRetryWait, err)
+			}
 		}
 
 		return nil
 	}
 
 	var lastResp *http.Response = nil
+	authRefreshed := false
 
 	for t.Retries <= t.Service.Retries {
 
-		// If rate limiter is present on service
-		// ensure limits
-		if t.Service.HasRateLimiter() {
-			// NOTE: Check if the wait lock requests for all services.
-			err := t.Service.GetRateLimiter().Wait(ctx)
+		if ctx.Err() != nil {
+			return newInterruptedError(t, PhaseRequest, ctx.Err())
+		}
+
+		err := g.waitRateLimiter(ctx, t, t.Node)
+		if err != nil {
+			return err
+		}
+
+		res, err := g.doAttempt(ctx, attempt, mws, t, &authRefreshed)
+		if err != nil {
+			return err
+		}
+		lastResp = t.Response
+		ans = res.err
+		if ans == nil {
+			break
+		}
+		if !res.retry {
+			return ans
+		}
+		err = handleRetry(res.retryAfter, res.sameNode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ans != nil {
+		t.Response = lastResp
+		t.Retries--
+	}
+
+	return ans
+}
+
+// attemptResult is the result of an attempt of a ticket.
+type attemptResult struct {
+	// The error of the attempt. Nil with a valid response.
+	err error
+	// The attempt could be retried.
+	retry bool
+	// The interval of the Retry-After header. With sameNode the
+	// retry is executed on the same node after the interval.
+	retryAfter time.Duration
+	sameNode   bool
+}
+
+// doAttempt executes the request of the ticket on the node of the
+// ticket and checks the response. A 401 response is retried once on
+// the same node with new credentials if authRefreshed is false. The
+// error returned stops the attempts of the ticket.
+func (g *RestGuard) doAttempt(ctx context.Context, attempt specs.AttemptFunc,
+	mws []specs.Middleware, t *specs.RestTicket, authRefreshed *bool) (*attemptResult, error) {
+	for {
+		node := t.Node
+		if d := node.GetCooldown(); d > 0 {
+			// The node is cooling-down after a Retry-After header.
+			err := sleepContext(ctx, d)
 			if err != nil {
-				return fmt.Errorf("error on rate limiting: %s", err.Error())
+				return nil, newInterruptedError(t, PhaseRetryWait, err)
+			}
+		}
+
+		node.AddOutstanding(1)
+		attemptStart := time.Now()
+		resp, err := attempt(t, t.Request)
+		node.AddOutstanding(-1)
+		if err != nil {
+			if ctx.Err() == nil {
+				g.Metrics.ObserveTransportError(t.Service.GetName(), node.Name,
+					time.Since(attemptStart))
+				g.adaptRateLimiter(t.Service, resp, err)
 			}
+		} else {
+			g.adaptRateLimiter(t.Service, resp, nil)
+			g.Metrics.ObserveResponse(t.Service.GetName(), node.Name,
+				resp.StatusCode, time.Since(attemptStart))
+			t.Service.ObserveLatency(time.Since(attemptStart))
 		}
-		resp, err := c.Do(t.Request)
 		t.Response = resp
-		lastResp = resp
 		if t.RequestCloseCb != nil {
 			t.RequestCloseCb(t)
 		}
-		if err != nil {
-			ans = err
-			err = handleRetry()
-			if err != nil {
-				return err
-			}
-		} else {
-			ans = nil
-			valid, errValid := t.Service.RespValidatorCb(t)
-			if !valid {
-				if errValid != nil {
-					ans = errValid
-				} else {
-					ans = errors.New("Received invalid response")
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && !*authRefreshed {
+			a := t.Service.GetAuthenticator(node)
+			if ra, ok := a.(specs.RefreshableAuthenticator); ok {
+				// Force new credentials and retry once on the same node.
+				*authRefreshed = true
+				for _, mw := range mws {
+					if o, ok := mw.(specs.ValidationObserver); ok {
+						o.OnValidation(t, false, errors.New("Received unauthorized response"))
+					}
 				}
-				err = handleRetry()
+				resp.Body.Close()
+				ra.Invalidate()
+				err = g.renewRequest(ctx, t, t.Request, node)
 				if err != nil {
-					return err
+					return nil, err
 				}
+				continue
+			}
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, newInterruptedError(t, PhaseRequest, ctx.Err())
+			}
+			if b := t.Service.GetBreaker(t.Node); b != nil {
+				b.OnFailure()
+			}
+			return &attemptResult{
+				err:   err,
+				retry: t.Service.CanRetry(t.Request, isConnectError(err)),
+			}, nil
+		}
+
+		valid, errValid := validateResponse(t)
+		for _, mw := range mws {
+			if o, ok := mw.(specs.ValidationObserver); ok {
+				o.OnValidation(t, valid, errValid)
+			}
+		}
+		if b := t.Service.GetBreaker(t.Node); b != nil {
+			if valid {
+				b.OnSuccess()
 			} else {
-				ans = nil
-				break
+				b.OnFailure()
 			}
 		}
-	}
+		if valid {
+			return &attemptResult{}, nil
+		}
 
-	if ans != nil {
-		t.Response = lastResp
-		t.Retries--
-	}
+		g.Metrics.ObserveRejection(t.Service.GetName(), t.Node.Name)
+		ans := &attemptResult{err: errValid}
+		if errValid == nil {
+			ans.err = errors.New("Received invalid response")
+		}
 
-	return ans
+		if t.Service.HasRetryAfter() {
+			d, ok := specs.ParseRetryAfter(t.Response)
+			if ok {
+				maxWait := t.Service.GetRetryAfterMax()
+				if maxWait > 0 && d > maxWait {
+					return nil, &RetryAfterError{
+						TicketId: t.Id,
+						Node:     t.Node.Name,
+						Delay:    d,
+						Max:      maxWait,
+					}
+				}
+				if t.Service.RetryAfterMode == specs.RetryAfterCooldown {
+					t.Node.SetCooldown(time.Now().Add(d))
+				} else {
+					ans.retryAfter = d
+					ans.sameNode = true
+				}
+			}
+		}
+
+		ans.retry = t.Service.CanRetry(t.Request, false)
+		return ans, nil
+	}
 }
 
-func (g *RestGuard) DoWithTimeout(t *specs.RestTicket, timeoutSec int) error {
+func (g *RestGuard) newTimeoutClient(timeoutSec int) (*http.Client, error) {
 	// Could be needed to hava a way to execute HTTP call with a custom
 	// timeout. In this case, I create a new client with the timeout
-	// in input.
+	// in input that shares the transport and the connections.
+
+	reqsTimeout, err := time.ParseDuration(fmt.Sprintf("%ds", timeoutSec))
+	if err != nil {
+		return nil, err
+	}
 
-	origTransport := g.Client.Transport.(*http.Transport)
+	return &http.Client{
+		Transport:     g.Client.Transport,
+		CheckRedirect: g.Client.CheckRedirect,
+		Jar:           g.Client.Jar,
+		Timeout:       reqsTimeout,
+	}, nil
+}
 
-	transport := &http.Transport{
-		Proxy:               http.ProxyFromEnvironment,
-		MaxIdleConns:        origTransport.MaxIdleConns,
-		IdleConnTimeout:     origTransport.IdleConnTimeout,
-		MaxIdleConnsPerHost: origTransport.MaxIdleConnsPerHost,
-		MaxConnsPerHost:     origTransport.MaxConnsPerHost,
+// nodeClient returns the client with the transport of the TLS profile
+// of the node. The settings of the client in input are preserved.
+func (g *RestGuard) nodeClient(c *http.Client, s *specs.RestService, n *specs.RestNode) (*http.Client, error) {
+	profile := s.GetTLS(n)
+	if profile == nil {
+		return c, nil
 	}
 
-	if origTransport.TLSClientConfig != nil &&
-		origTransport.TLSClientConfig.InsecureSkipVerify {
-		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	transport, err := g.getTransport(profile)
+	if err != nil {
+		return nil, err
 	}
 
-	reqsTimeout, err := time.ParseDuration(fmt.Sprintf("%ds", timeoutSec))
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: c.CheckRedirect,
+		Jar:           c.Jar,
+		Timeout:       c.Timeout,
+	}, nil
+}
+
+// getTransport returns the transport of the TLS profile. The nodes
+// with the same profile share the transport and the connections.
+func (g *RestGuard) getTransport(profile *specs.RestTLS) (*http.Transport, error) {
+	key := profile.GetKey()
+
+	g.transportsMutex.Lock()
+	defer g.transportsMutex.Unlock()
+
+	if g.transports == nil {
+		g.transports = make(map[string]*http.Transport, 0)
+	}
+	if tr, ok := g.transports[key]; ok {
+		return tr, nil
+	}
+
+	tlsConfig, err := profile.NewConfig()
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error on setup TLS: %s", err.Error())
 	}
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   reqsTimeout,
+	var transport *http.Transport
+	if base, ok := g.Client.Transport.(*http.Transport); ok {
+		transport = base.Clone()
+		if base.TLSClientConfig != nil && base.TLSClientConfig.InsecureSkipVerify {
+			tlsConfig.InsecureSkipVerify = true
+		}
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	g.transports[key] = transport
+	return transport, nil
+}
+
+func (g *RestGuard) DoWithTimeout(t *specs.RestTicket, timeoutSec int) error {
+	return g.DoWithTimeoutContext(context.Background(), t, timeoutSec)
+}
+
+// DoWithTimeoutContext executes the ticket with a custom timeout
+// and stops the retries loop when the context is done.
+func (g *RestGuard) DoWithTimeoutContext(ctx context.Context, t *specs.RestTicket, timeoutSec int) error {
+	client, err := g.newTimeoutClient(timeoutSec)
+	if err != nil {
+		return err
 	}
 
-	return g.doClient(client, t)
+	return g.doClient(ctx, client, t, true)
 }
 
 func (g *RestGuard) Do(t *specs.RestTicket) error {
-	return g.doClient(g.Client, t)
+	return g.DoContext(context.Background(), t)
+}
+
+// DoContext executes the ticket and stops the retries loop when the
+// context is done. On cancellation an *InterruptedError is returned.
+func (g *RestGuard) DoContext(ctx context.Context, t *specs.RestTicket) error {
+	return g.doClient(ctx, g.Client, t, true)
 }
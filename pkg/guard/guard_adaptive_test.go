@@ -0,0 +1,72 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Adaptive Rate Limiter Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+		server.RouteToHandler("GET", "/throttled",
+			ghttp.RespondWith(http.StatusTooManyRequests, "KO"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.SetOption(specs.ServiceRateLimiter, "100")
+		service.SetOption(specs.ServiceRateLimiterMode, specs.RateLimiterAdaptive)
+		service.SetOption(specs.ServiceRateLimiterMax, "200")
+		service.SetOption(specs.ServiceRateLimiterIncrease, "100")
+		Expect(service.SetRateLimiter()).Should(BeNil())
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	doTicket := func(path string) error {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", path)
+		Expect(err).Should(BeNil())
+		return guard.Do(t)
+	}
+
+	It("Follow the feedback of the server", func() {
+		Expect(doTicket("/ok")).Should(BeNil())
+		Expect(service.GetRateLimit()).Should(Equal(101.0))
+
+		Expect(doTicket("/throttled")).ShouldNot(BeNil())
+		Expect(service.GetRateLimit()).Should(Equal(50.5))
+
+		rec := httptest.NewRecorder()
+		guard.Metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(rec.Body.String()).Should(ContainSubstring(
+			`restguard_ratelimiter_rate{service="local-tester"} 50.5`))
+	})
+
+})
@@ -0,0 +1,162 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Atomic Download Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		dir     string
+		target  string
+		body    = "new artefact content"
+		// The files of the directory seen during the transfer.
+		seen []string
+	)
+
+	listDir := func() []string {
+		entries, err := os.ReadDir(dir)
+		Expect(err).Should(BeNil())
+		ans := []string{}
+		for _, e := range entries {
+			ans = append(ans, e.Name())
+		}
+		return ans
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir = GinkgoT().TempDir()
+		target = filepath.Join(dir, "artefact.bin")
+		seen = nil
+
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/file", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write([]byte(body[:5]))
+			w.(http.Flusher).Flush()
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				seen = append(seen, e.Name())
+			}
+			w.Write([]byte(body[5:]))
+		})
+		server.RouteToHandler("GET", "/ko", ghttp.RespondWith(http.StatusInternalServerError, nil))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	download := func(path string, opts *g.DownloadOptions) (*specs.RestArtefact, error) {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", path)
+		Expect(err).Should(BeNil())
+		return guard.DoDownloadWithOptions(context.Background(), t, target, opts)
+	}
+
+	It("Write the artefact on a temporary file", func() {
+		artefact, err := download("/file", nil)
+		Expect(err).Should(BeNil())
+		Expect(artefact.Path).Should(Equal(target))
+
+		// The artefact is not visible during the transfer.
+		Expect(seen).Should(HaveLen(1))
+		Expect(seen[0]).Should(HavePrefix(".artefact.bin."))
+		Expect(seen[0]).Should(HaveSuffix(".tmp"))
+
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal(body))
+		Expect(listDir()).Should(Equal([]string{"artefact.bin"}))
+
+		// The new artefact has the permissions of os.Create.
+		ref := filepath.Join(GinkgoT().TempDir(), "ref")
+		Expect(os.WriteFile(ref, nil, 0666)).Should(BeNil())
+		refInfo, err := os.Stat(ref)
+		Expect(err).Should(BeNil())
+		info, err := os.Stat(target)
+		Expect(err).Should(BeNil())
+		Expect(info.Mode().Perm()).Should(Equal(refInfo.Mode().Perm()))
+	})
+
+	It("Keep the permissions of the existing artefact", func() {
+		Expect(os.WriteFile(target, []byte("old"), 0600)).Should(BeNil())
+		Expect(os.Chmod(target, 0640)).Should(BeNil())
+
+		_, err := download("/file", nil)
+		Expect(err).Should(BeNil())
+
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal(body))
+		info, err := os.Stat(target)
+		Expect(err).Should(BeNil())
+		Expect(info.Mode().Perm()).Should(Equal(os.FileMode(0640)))
+	})
+
+	It("Preserve the existing artefact on failure", func() {
+		Expect(os.WriteFile(target, []byte("good"), 0644)).Should(BeNil())
+
+		_, err := download("/ko", nil)
+		Expect(err).ShouldNot(BeNil())
+
+		// The verification fails on the temporary file.
+		_, err = download("/file", &g.DownloadOptions{
+			Expected: &specs.RestArtefact{Md5: fmt.Sprintf("%x", md5.Sum([]byte("good")))},
+		})
+		Expect(err).ShouldNot(BeNil())
+
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("good"))
+		Expect(listDir()).Should(Equal([]string{"artefact.bin"}))
+	})
+
+	It("Rename the part file after the checks", func() {
+		Expect(os.WriteFile(target, []byte("good"), 0644)).Should(BeNil())
+
+		_, err := download("/file", &g.DownloadOptions{
+			Resume:   true,
+			Expected: &specs.RestArtefact{Md5: fmt.Sprintf("%x", md5.Sum([]byte("good")))},
+		})
+		Expect(err).ShouldNot(BeNil())
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("good"))
+
+		artefact, err := download("/file", &g.DownloadOptions{Resume: true})
+		Expect(err).Should(BeNil())
+		Expect(artefact.Path).Should(Equal(target))
+		Expect(listDir()).Should(Equal([]string{"artefact.bin"}))
+	})
+
+})
@@ -0,0 +1,292 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+var _ = Describe("Authentication Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		node    *specs.RestNode
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.SetAllowUnhandledRequests(true)
+		server.SetUnhandledRequestStatusCode(http.StatusOK)
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		node = specs.NewRestNode("LocalServer", server.Addr(), false)
+		Expect(guard.AddRestNode(service.GetName(), node)).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	doTicket := func() *http.Request {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/data?x=1")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).Should(BeNil())
+		reqs := server.ReceivedRequests()
+		return reqs[len(reqs)-1]
+	}
+
+	It("Set the static credentials", func() {
+		service.Authenticator = &specs.BasicAuth{Username: "user", Password: "pass"}
+		user, pass, ok := doTicket().BasicAuth()
+		Expect(ok).Should(BeTrue())
+		Expect(user).Should(Equal("user"))
+		Expect(pass).Should(Equal("pass"))
+
+		// The authenticator of the node overrides the service.
+		node.Auth = &specs.RestAuth{Type: specs.AuthBearer, Token: "secret"}
+		Expect(node.SetAuthenticator()).Should(BeNil())
+		Expect(doTicket().Header.Get("Authorization")).Should(Equal("Bearer secret"))
+	})
+
+	It("Set the API key", func() {
+		service.Auth = &specs.RestAuth{Type: specs.AuthApiKey, Name: "X-Api-Key", Value: "k1"}
+		Expect(service.SetAuthenticator()).Should(BeNil())
+		Expect(doTicket().Header.Get("X-Api-Key")).Should(Equal("k1"))
+
+		service.Auth = &specs.RestAuth{Type: specs.AuthApiKey, Name: "api_key",
+			Value: "k2", In: specs.ApiKeyInQuery}
+		Expect(service.SetAuthenticator()).Should(BeNil())
+		req := doTicket()
+		Expect(req.URL.Query().Get("api_key")).Should(Equal("k2"))
+		Expect(req.URL.Query().Get("x")).Should(Equal("1"))
+	})
+
+	Context("Nodes with different credentials", func() {
+
+		var other *ghttp.Server
+
+		BeforeEach(func() {
+			server.RouteToHandler("GET", "/data", ghttp.RespondWith(http.StatusInternalServerError, ""))
+			other = ghttp.NewServer()
+			other.SetAllowUnhandledRequests(true)
+			other.SetUnhandledRequestStatusCode(http.StatusOK)
+			service.Retries = 1
+			service.RetryIntervalMs = 0
+			Expect(guard.AddRestNode(service.GetName(),
+				specs.NewRestNode("OtherServer", other.Addr(), false))).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			other.Close()
+		})
+
+		retry := func() *http.Request {
+			t := service.GetTicket()
+			defer t.Rip()
+			_, err := guard.CreateRequest(t, "GET", "/data")
+			Expect(err).Should(BeNil())
+			t.Request.Header.Set("X-Custom", "value")
+			Expect(guard.Do(t)).Should(BeNil())
+			Expect(t.Node.Name).Should(Equal("OtherServer"))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+			Expect(other.ReceivedRequests()).Should(HaveLen(1))
+			return other.ReceivedRequests()[0]
+		}
+
+		It("Don't send the bearer token to the node without authenticator", func() {
+			node.Authenticator = &specs.BearerAuth{Token: "secret-for-A"}
+
+			req := retry()
+			Expect(server.ReceivedRequests()[0].Header.Get("Authorization")).Should(
+				Equal("Bearer secret-for-A"))
+			Expect(req.Header.Get("Authorization")).Should(BeEmpty())
+			Expect(req.Header.Get("X-Custom")).Should(Equal("value"))
+		})
+
+		It("Replace the API key with the key of the next node", func() {
+			node.Authenticator = &specs.ApiKeyAuth{Name: "X-Api-Key", Value: "key-for-A"}
+			service.GetNodes()[1].Authenticator = &specs.ApiKeyAuth{Name: "X-Other-Key", Value: "key-for-B"}
+
+			req := retry()
+			Expect(req.Header.Get("X-Api-Key")).Should(BeEmpty())
+			Expect(req.Header.Get("X-Other-Key")).Should(Equal("key-for-B"))
+			Expect(req.Header.Get("X-Custom")).Should(Equal("value"))
+		})
+
+		It("Use the credentials of the service on the next node", func() {
+			node.Authenticator = &specs.BasicAuth{Username: "user-a", Password: "pass-a"}
+			service.Authenticator = &specs.BearerAuth{Token: "service"}
+
+			req := retry()
+			Expect(req.Header.Get("Authorization")).Should(Equal("Bearer service"))
+		})
+	})
+
+	Context("OAuth2 client credentials", func() {
+
+		var (
+			tokenServer *httptest.Server
+			requested   atomic.Int32
+			issued      atomic.Int32
+			expiresIn   int
+			tokenDelay  time.Duration
+		)
+
+		BeforeEach(func() {
+			requested.Store(0)
+			issued.Store(0)
+			expiresIn = 3600
+			tokenDelay = 0
+			tokenServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer GinkgoRecover()
+				Expect(r.ParseForm()).Should(BeNil())
+				Expect(r.PostForm.Get("grant_type")).Should(Equal("client_credentials"))
+				Expect(r.PostForm.Get("scope")).Should(Equal("read write"))
+				id, secret, ok := r.BasicAuth()
+				Expect(ok).Should(BeTrue())
+				Expect(id + ":" + secret).Should(Equal("client:secret"))
+
+				requested.Add(1)
+				time.Sleep(tokenDelay)
+				n := issued.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":%d}`,
+					n, expiresIn)
+			}))
+
+			service.Auth = &specs.RestAuth{
+				Type:         specs.AuthOAuth2,
+				TokenUrl:     tokenServer.URL,
+				ClientId:     "client",
+				ClientSecret: "secret",
+				Scopes:       []string{"read", "write"},
+			}
+			Expect(service.SetAuthenticator()).Should(BeNil())
+		})
+
+		AfterEach(func() {
+			tokenServer.Close()
+		})
+
+		It("Cache the token", func() {
+			Expect(doTicket().Header.Get("Authorization")).Should(Equal("Bearer token1"))
+			Expect(doTicket().Header.Get("Authorization")).Should(Equal("Bearer token1"))
+			Expect(issued.Load()).Should(Equal(int32(1)))
+		})
+
+		It("Request the token once for the concurrent requests", func() {
+			tokenDelay = 200 * time.Millisecond
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					t := service.GetTicket()
+					defer t.Rip()
+					req, err := guard.CreateRequest(t, "GET", "/data")
+					Expect(err).Should(BeNil())
+					Expect(req.Header.Get("Authorization")).Should(Equal("Bearer token1"))
+				}()
+			}
+
+			// The requests waiting the token are interrupted by the context.
+			Eventually(requested.Load).Should(Equal(int32(1)))
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			t := service.GetTicket()
+			_, err := guard.CreateRequestContext(ctx, t, "GET", "/data")
+			Expect(err).ShouldNot(BeNil())
+			Expect(issued.Load()).Should(Equal(int32(0)))
+
+			wg.Wait()
+			Expect(issued.Load()).Should(Equal(int32(1)))
+		})
+
+		It("Request the token with the client of the guard", func() {
+			var sent atomic.Int32
+			next := guard.Client.Transport
+			if next == nil {
+				next = http.DefaultTransport
+			}
+			guard.Client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.URL.String() == tokenServer.URL {
+					sent.Add(1)
+				}
+				return next.RoundTrip(r)
+			})
+
+			Expect(doTicket().Header.Get("Authorization")).Should(Equal("Bearer token1"))
+			Expect(sent.Load()).Should(Equal(int32(1)))
+		})
+
+		It("Refresh the token before the expiry", func() {
+			expiresIn = 10
+			Expect(doTicket().Header.Get("Authorization")).Should(Equal("Bearer token1"))
+			Expect(doTicket().Header.Get("Authorization")).Should(Equal("Bearer token2"))
+		})
+
+		It("Refresh the token once after a 401", func() {
+			server.RouteToHandler("GET", "/data", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") == "Bearer token1" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := doTicket()
+			Expect(req.Header.Get("Authorization")).Should(Equal("Bearer token2"))
+			Expect(server.ReceivedRequests()).Should(HaveLen(2))
+
+			// A second 401 is not refreshed again.
+			server.RouteToHandler("GET", "/data", ghttp.RespondWith(http.StatusUnauthorized, ""))
+			t := service.GetTicket()
+			_, err := guard.CreateRequest(t, "GET", "/data")
+			Expect(err).Should(BeNil())
+			Expect(guard.Do(t)).ShouldNot(BeNil())
+			Expect(t.Response.StatusCode).Should(Equal(http.StatusUnauthorized))
+			t.Rip()
+			Expect(server.ReceivedRequests()).Should(HaveLen(4))
+			Expect(issued.Load()).Should(Equal(int32(3)))
+		})
+
+		It("Report the token errors", func() {
+			tokenServer.Config.Handler = http.NotFoundHandler()
+
+			t := service.GetTicket()
+			_, err := guard.CreateRequest(t, "GET", "/data")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("token request failed with status 404"))
+		})
+	})
+
+})
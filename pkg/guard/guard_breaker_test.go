@@ -0,0 +1,145 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Circuit Breaker Tests", func() {
+
+	var (
+		server      *ghttp.Server
+		node        *specs.RestNode
+		nodeFailed  *specs.RestNode
+		guard       *g.RestGuard
+		service     *specs.RestService
+		transitions []string
+		mutex       sync.Mutex
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+
+		node = specs.NewRestNode("LocalServer", server.Addr(), false)
+		nodeFailed = specs.NewRestNode("failed", "127.0.0.1:10000", false)
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+
+		transitions = []string{}
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		service.BreakerFailureThreshold = 1
+		service.BreakerOpenMs = 200
+		service.BreakerStateCb = func(s *specs.RestService, n *specs.RestNode, from, to specs.BreakerState) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			transitions = append(transitions, n.Name+":"+from.String()+"->"+to.String())
+		}
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(), nodeFailed)).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(), node)).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Skip the open node on new tickets", func() {
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("failed"))
+		Expect(guard.Do(t)).Should(BeNil())
+		t.Rip()
+		Expect(t.Retries).Should(Equal(1))
+		Expect(service.GetBreakerState(nodeFailed)).Should(Equal(specs.BreakerOpen))
+		Expect(service.GetBreakerState(node)).Should(Equal(specs.BreakerClosed))
+
+		t = service.GetTicket()
+		_, err = guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("LocalServer"))
+		Expect(guard.Do(t)).Should(BeNil())
+		t.Rip()
+		Expect(t.Retries).Should(Equal(0))
+
+		// After the open duration the node is probed again.
+		time.Sleep(250 * time.Millisecond)
+		t = service.GetTicket()
+		_, err = guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("failed"))
+		Expect(guard.Do(t)).Should(BeNil())
+		t.Rip()
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		Expect(transitions).Should(Equal([]string{
+			"failed:closed->open",
+			"failed:open->half-open",
+			"failed:half-open->open",
+		}))
+	})
+
+	It("Don't use the probe of a half-open node without retries", func() {
+		service.Retries = 0
+		service.BreakerHalfOpenProbes = 1
+		b := service.GetBreaker(node)
+		b.OnFailure()
+		time.Sleep(250 * time.Millisecond)
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("failed"))
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		Expect(t.Node.Name).Should(Equal("failed"))
+		t.Rip()
+		Expect(service.GetBreakerState(node)).Should(Equal(specs.BreakerHalfOpen))
+
+		// The probe of the half-open node is still available.
+		t = service.GetTicket()
+		defer t.Rip()
+		_, err = guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("LocalServer"))
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(service.GetBreakerState(node)).Should(Equal(specs.BreakerClosed))
+	})
+
+	It("Close the breaker after the probes", func() {
+		b := specs.NewCircuitBreaker(2, 50*time.Millisecond, 2)
+		b.OnFailure()
+		Expect(b.GetState()).Should(Equal(specs.BreakerClosed))
+		b.OnFailure()
+		Expect(b.GetState()).Should(Equal(specs.BreakerOpen))
+		Expect(b.Ready()).Should(BeFalse())
+
+		time.Sleep(60 * time.Millisecond)
+		Expect(b.Ready()).Should(BeTrue())
+		Expect(b.GetState()).Should(Equal(specs.BreakerHalfOpen))
+		b.Acquire()
+		b.Acquire()
+		Expect(b.Ready()).Should(BeFalse())
+		b.OnSuccess()
+		Expect(b.GetState()).Should(Equal(specs.BreakerHalfOpen))
+		b.OnSuccess()
+		Expect(b.GetState()).Should(Equal(specs.BreakerClosed))
+	})
+
+})
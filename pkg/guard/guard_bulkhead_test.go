@@ -0,0 +1,170 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Bulkhead Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		unblock chan bool
+		closed  bool
+	)
+
+	release := func() {
+		if !closed {
+			close(unblock)
+			closed = true
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		unblock = make(chan bool)
+		closed = false
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/slow", func(w http.ResponseWriter, r *http.Request) {
+			<-unblock
+		})
+		server.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+		server.RouteToHandler("GET", "/file", func(w http.ResponseWriter, r *http.Request) {
+			// Send the headers and block the body.
+			w.Write([]byte("first chunk"))
+			w.(http.Flusher).Flush()
+			<-unblock
+			w.Write([]byte(" last chunk"))
+		})
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.SetOption(specs.ServiceBulkheadMaxConcurrent, "1")
+		service.SetOption(specs.ServiceBulkheadMaxQueue, "1")
+		service.SetOption(specs.ServiceBulkheadWaitMs, "100")
+		Expect(service.SetBulkhead()).Should(BeNil())
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		release()
+		server.Close()
+	})
+
+	doTicket := func(path string) error {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", path)
+		Expect(err).Should(BeNil())
+		return guard.Do(t)
+	}
+
+	It("Reject the tickets over the limits", func() {
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- doTicket("/slow")
+		}()
+		Eventually(service.GetBulkhead().GetInFlight).Should(Equal(1))
+
+		// The queue is used by the second ticket until the timeout.
+		queued := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			queued <- doTicket("/ok")
+		}()
+		Eventually(service.GetBulkhead().GetQueued).Should(Equal(1))
+
+		err := doTicket("/ok")
+		Expect(errors.Is(err, specs.ErrBulkheadFull)).Should(BeTrue())
+
+		Eventually(queued).Should(Receive(MatchError(specs.ErrBulkheadFull)))
+
+		release()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(service.GetBulkhead().GetInFlight()).Should(Equal(0))
+		Expect(doTicket("/ok")).Should(BeNil())
+	})
+
+	It("A queued ticket gets the slot released", func() {
+		delete(service.Options, specs.ServiceBulkheadWaitMs)
+		service.BulkheadWaitMs = 0
+		Expect(service.SetBulkhead()).Should(BeNil())
+
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			done <- doTicket("/slow")
+		}()
+		Eventually(service.GetBulkhead().GetInFlight).Should(Equal(1))
+
+		queued := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			queued <- doTicket("/ok")
+		}()
+		Eventually(service.GetBulkhead().GetQueued).Should(Equal(1))
+		Consistently(queued, 200*time.Millisecond).ShouldNot(Receive())
+
+		release()
+		Eventually(done).Should(Receive(BeNil()))
+		Eventually(queued).Should(Receive(BeNil()))
+	})
+
+	It("Hold the slot until the body is downloaded", func() {
+		target := filepath.Join(GinkgoT().TempDir(), "artefact.bin")
+		done := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			t := service.GetTicket()
+			defer t.Rip()
+			_, err := guard.CreateRequest(t, "GET", "/file")
+			Expect(err).Should(BeNil())
+			_, err = guard.DoDownload(t, target)
+			done <- err
+		}()
+		// Wait the first chunk on the temporary file.
+		Eventually(func() int64 {
+			matches, _ := filepath.Glob(filepath.Join(filepath.Dir(target), ".artefact.bin.*.tmp"))
+			if len(matches) == 0 {
+				return 0
+			}
+			info, err := os.Stat(matches[0])
+			if err != nil {
+				return 0
+			}
+			return info.Size()
+		}).Should(BeNumerically(">", 0))
+
+		Expect(service.GetBulkhead().GetInFlight()).Should(Equal(1))
+		err := doTicket("/ok")
+		Expect(errors.Is(err, specs.ErrBulkheadFull)).Should(BeTrue())
+
+		release()
+		Eventually(done).Should(Receive(BeNil()))
+		Expect(service.GetBulkhead().GetInFlight()).Should(Equal(0))
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("first chunk last chunk"))
+	})
+
+})
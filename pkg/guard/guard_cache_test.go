@@ -0,0 +1,265 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Response Cache Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		node    *specs.RestNode
+	)
+
+	newGuard := func(cache string, dir string) {
+		var err error
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.SetOption(specs.ServiceCache, cache)
+		service.CacheDir = dir
+		Expect(service.SetCache()).Should(BeNil())
+		guard.AddService(service.GetName(), service)
+		node = specs.NewRestNode("LocalServer", server.Addr(), false)
+		Expect(guard.AddRestNode(service.GetName(), node)).Should(BeNil())
+	}
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		newGuard(specs.CacheMemory, "")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	doTicket := func(ctx context.Context, method, path string, headers map[string]string) (*specs.RestTicket, string, error) {
+		t := service.GetTicket()
+		defer t.Rip()
+		req, err := guard.CreateRequest(t, method, path)
+		Expect(err).Should(BeNil())
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		err = guard.DoContext(ctx, t)
+		if err != nil {
+			return t, "", err
+		}
+		data, err := io.ReadAll(t.Response.Body)
+		Expect(err).Should(BeNil())
+		return t, string(data), nil
+	}
+
+	It("Serve the fresh responses without node and rate limiter", func() {
+		server.RouteToHandler("GET", "/meta", ghttp.RespondWith(http.StatusOK, "meta-v1",
+			http.Header{"Cache-Control": []string{"max-age=60"}}))
+
+		t, body, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v1"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusMiss))
+
+		// The next requests must wait one hour on the rate limiter
+		// and the node is not available.
+		service.SetOption(specs.ServiceRateLimiter, "1/h")
+		Expect(service.SetRateLimiter()).Should(BeNil())
+		service.GetRateLimiter().Allow()
+		node.SetDisable(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		for i := 0; i < 3; i++ {
+			t, body, err = doTicket(ctx, "GET", "/meta", nil)
+			Expect(err).Should(BeNil())
+			Expect(body).Should(Equal("meta-v1"))
+			Expect(t.CacheStatus).Should(Equal(specs.CacheStatusHit))
+			Expect(t.GetNode()).Should(BeNil())
+			Expect(t.Response.StatusCode).Should(Equal(http.StatusOK))
+			Expect(t.Response.Header.Get("Age")).ShouldNot(BeEmpty())
+		}
+		Expect(server.ReceivedRequests()).Should(HaveLen(1))
+	})
+
+	It("Revalidate the stale responses with the ETag", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeader(http.Header{"If-None-Match": nil}),
+				ghttp.RespondWith(http.StatusOK, "meta-v1", http.Header{
+					"Cache-Control": []string{"no-cache"},
+					"Etag":          []string{`"v1"`},
+				}),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeader(http.Header{"If-None-Match": []string{`"v1"`}}),
+				ghttp.RespondWith(http.StatusNotModified, nil),
+			),
+		)
+
+		_, body, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v1"))
+
+		t, body, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v1"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusRevalidated))
+		Expect(t.Response.StatusCode).Should(Equal(http.StatusOK))
+		Expect(t.GetNode()).ShouldNot(BeNil())
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("Revalidate the stale responses with the Last-Modified", func() {
+		lastModified := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		server.AppendHandlers(
+			ghttp.RespondWith(http.StatusOK, "meta-v1", http.Header{
+				"Cache-Control": []string{"max-age=0"},
+				"Last-Modified": []string{lastModified},
+			}),
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeader(http.Header{"If-Modified-Since": []string{lastModified}}),
+				ghttp.RespondWith(http.StatusOK, "meta-v2", http.Header{
+					"Cache-Control": []string{"max-age=60"},
+				}),
+			),
+		)
+
+		_, _, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+
+		// The resource is changed.
+		t, body, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v2"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusMiss))
+
+		t, body, err = doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v2"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusHit))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("Select the variant with the Vary header", func() {
+		server.RouteToHandler("GET", "/meta", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept")
+			w.Write([]byte(r.Header.Get("Accept")))
+		})
+
+		_, body, err := doTicket(context.Background(), "GET", "/meta",
+			map[string]string{"Accept": "application/json"})
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("application/json"))
+
+		t, body, err := doTicket(context.Background(), "GET", "/meta",
+			map[string]string{"Accept": "text/plain"})
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("text/plain"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusMiss))
+
+		t, body, err = doTicket(context.Background(), "GET", "/meta",
+			map[string]string{"Accept": "text/plain"})
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("text/plain"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusHit))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("Don't store the responses with no-store", func() {
+		server.RouteToHandler("GET", "/meta", ghttp.RespondWith(http.StatusOK, "meta-v1",
+			http.Header{"Cache-Control": []string{"no-store, max-age=60"}}))
+
+		for i := 0; i < 2; i++ {
+			t, _, err := doTicket(context.Background(), "GET", "/meta", nil)
+			Expect(err).Should(BeNil())
+			Expect(t.CacheStatus).Should(Equal(specs.CacheStatusMiss))
+		}
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("Invalidate the response after an unsafe method", func() {
+		server.RouteToHandler("GET", "/meta", ghttp.RespondWith(http.StatusOK, "meta-v1",
+			http.Header{"Cache-Control": []string{"max-age=60"}}))
+		server.RouteToHandler("DELETE", "/meta", ghttp.RespondWith(http.StatusOK, nil))
+
+		_, _, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		_, _, err = doTicket(context.Background(), "DELETE", "/meta", nil)
+		Expect(err).Should(BeNil())
+		t, _, err := doTicket(context.Background(), "GET", "/meta", nil)
+		Expect(err).Should(BeNil())
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusMiss))
+		Expect(server.ReceivedRequests()).Should(HaveLen(3))
+	})
+
+	It("Keep the responses on disk", func() {
+		dir := GinkgoT().TempDir()
+		newGuard(specs.CacheDisk, dir)
+		server.RouteToHandler("GET", "/meta", ghttp.RespondWith(http.StatusOK, "meta-v1",
+			http.Header{"Cache-Control": []string{"max-age=60"}}))
+
+		_, _, err := doTicket(context.Background(), "GET", "/meta?arch=amd64", nil)
+		Expect(err).Should(BeNil())
+
+		// A new guard reads the entries of the directory.
+		newGuard(specs.CacheDisk, dir)
+		t, body, err := doTicket(context.Background(), "GET", "/meta?arch=amd64", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v1"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusHit))
+
+		t, _, err = doTicket(context.Background(), "GET", "/meta?arch=arm64", nil)
+		Expect(err).Should(BeNil())
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusMiss))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("Don't store the API key of the query", func() {
+		dir := GinkgoT().TempDir()
+		newGuard(specs.CacheDisk, dir)
+		service.Authenticator = &specs.ApiKeyAuth{Name: "api_key", Value: "top-secret",
+			In: specs.ApiKeyInQuery}
+		server.RouteToHandler("GET", "/meta", ghttp.RespondWith(http.StatusOK, "meta-v1",
+			http.Header{"Cache-Control": []string{"max-age=60"}}))
+
+		_, _, err := doTicket(context.Background(), "GET", "/meta?arch=amd64", nil)
+		Expect(err).Should(BeNil())
+		Expect(server.ReceivedRequests()[0].URL.Query().Get("api_key")).Should(Equal("top-secret"))
+
+		files, err := filepath.Glob(filepath.Join(dir, "*"))
+		Expect(err).Should(BeNil())
+		Expect(files).ShouldNot(BeEmpty())
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			Expect(err).Should(BeNil())
+			Expect(string(data)).ShouldNot(ContainSubstring("top-secret"))
+		}
+
+		// The hit skips the selection of the node.
+		t, body, err := doTicket(context.Background(), "GET", "/meta?arch=amd64", nil)
+		Expect(err).Should(BeNil())
+		Expect(body).Should(Equal("meta-v1"))
+		Expect(t.CacheStatus).Should(Equal(specs.CacheStatusHit))
+		Expect(t.GetNode()).Should(BeNil())
+		Expect(server.ReceivedRequests()).Should(HaveLen(1))
+	})
+
+})
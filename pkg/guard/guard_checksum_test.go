@@ -0,0 +1,127 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Checksum Verification Tests", func() {
+
+	var (
+		serverBad, serverGood *ghttp.Server
+		guard                 *g.RestGuard
+		service               *specs.RestService
+		target                string
+		body                  = "abcdefghijlmnopqrstuvwxyz"
+		expected              *specs.RestArtefact
+	)
+
+	BeforeEach(func() {
+		var err error
+		serverBad = ghttp.NewServer()
+		serverGood = ghttp.NewServer()
+		serverBad.RouteToHandler("GET", "/file",
+			ghttp.RespondWith(http.StatusOK, "stale content"))
+		serverGood.RouteToHandler("GET", "/file",
+			ghttp.RespondWith(http.StatusOK, body))
+		target = filepath.Join(GinkgoT().TempDir(), "artefact")
+		expected = &specs.RestArtefact{
+			Size:   int64(len(body)),
+			Sha512: fmt.Sprintf("%X", sha512.Sum512([]byte(body))),
+		}
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 3
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("bad", serverBad.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		serverBad.Close()
+		serverGood.Close()
+	})
+
+	download := func(resume bool) (*specs.RestTicket, *specs.RestArtefact, error) {
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+		artefact, err := guard.DoDownloadWithOptions(context.Background(), t, target,
+			&g.DownloadOptions{Expected: expected, Resume: resume})
+		t.Rip()
+		return t, artefact, err
+	}
+
+	It("Fail over to the next node", func() {
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("good", serverGood.Addr(), false))).Should(BeNil())
+
+		t, artefact, err := download(false)
+		Expect(err).Should(BeNil())
+		Expect(artefact).ShouldNot(BeNil())
+		Expect(artefact.Size).Should(Equal(int64(len(body))))
+		Expect(t.Node.Name).Should(Equal("good"))
+		Expect(t.FailedNodes.HasNode(specs.NewRestNode("bad", serverBad.Addr(), false))).Should(BeTrue())
+		Expect(target).Should(BeAnExistingFile())
+	})
+
+	It("Fail over to the next node on resume", func() {
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("good", serverGood.Addr(), false))).Should(BeNil())
+
+		t, artefact, err := download(true)
+		Expect(err).Should(BeNil())
+		Expect(artefact).ShouldNot(BeNil())
+		Expect(t.Node.Name).Should(Equal("good"))
+	})
+
+	It("Try all the nodes with the default retries", func() {
+		service.Retries = 0
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("bad2", serverBad.Addr(), false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("good", serverGood.Addr(), false))).Should(BeNil())
+
+		t, artefact, err := download(false)
+		Expect(err).Should(BeNil())
+		Expect(artefact).ShouldNot(BeNil())
+		Expect(t.Node.Name).Should(Equal("good"))
+		Expect(serverBad.ReceivedRequests()).Should(HaveLen(2))
+		Expect(serverGood.ReceivedRequests()).Should(HaveLen(1))
+	})
+
+	It("Return the mismatch error", func() {
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("bad2", serverBad.Addr(), false))).Should(BeNil())
+
+		_, artefact, err := download(false)
+		Expect(artefact).Should(BeNil())
+
+		var merr *g.ChecksumMismatchError
+		Expect(errors.As(err, &merr)).Should(BeTrue())
+		Expect(merr.Path).Should(Equal(target))
+		Expect(merr.Mismatches).Should(HaveLen(2))
+		Expect(merr.Mismatches[0].Node).Should(Equal("bad"))
+		Expect(merr.Mismatches[0].Field).Should(Equal("size"))
+		Expect(merr.Mismatches[1].Node).Should(Equal("bad2"))
+		Expect(target).ShouldNot(BeAnExistingFile())
+	})
+
+})
@@ -0,0 +1,189 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config Loader Tests", func() {
+
+	yamlConfig := `
+config:
+  user_agent: "test-agent"
+  reqs_timeout: 30
+services:
+  mirrors:
+    retries: 2
+    retry_interval_ms: 50
+    backoff: exponential
+    selector: round-robin
+    options:
+      rate_limiter: "10"
+    nodes:
+      - name: mirror1
+        base_url: mirror1.example.com/
+        ssl: true
+      - name: mirror2
+        base_url: mirror2.example.com
+        weight: 3
+        rate_limit: "3000/m burst=50"
+  api:
+    nodes:
+      - name: api1
+        base_url: 127.0.0.1:8080
+`
+
+	jsonConfig := `{
+  "services": {
+    "api": {
+      "retries": 1,
+      "nodes": [ { "name": "api1", "base_url": "127.0.0.1:8080" } ]
+    }
+  }
+}`
+
+	Context("YAML", func() {
+
+		It("Load services and nodes", func() {
+			guard, err := g.LoadConfig(strings.NewReader(yamlConfig), "yaml")
+			Expect(err).Should(BeNil())
+			Expect(guard.GetUserAgent()).Should(Equal("test-agent"))
+			Expect(guard.Services).Should(HaveLen(2))
+
+			s, err := guard.GetService("mirrors")
+			Expect(err).Should(BeNil())
+			Expect(s.GetName()).Should(Equal("mirrors"))
+			Expect(s.Retries).Should(Equal(2))
+			Expect(s.RetryIntervalMs).Should(Equal(50))
+			Expect(s.HasRateLimiter()).Should(BeTrue())
+			Expect(s.HasBackoffPolicy()).Should(BeTrue())
+			Expect(s.HasNodeSelector()).Should(BeTrue())
+			Expect(s.RespValidatorCb).ShouldNot(BeNil())
+			Expect(s.GetNodes()).Should(HaveLen(2))
+			Expect(s.GetNodes()[0].GetUrlPrefix()).Should(Equal("https://mirror1.example.com"))
+			Expect(s.GetNodes()[1].GetWeight()).Should(Equal(3))
+			Expect(s.GetNodes()[0].HasRateLimiter()).Should(BeFalse())
+			Expect(s.GetNodes()[1].HasRateLimiter()).Should(BeTrue())
+			Expect(s.GetNodes()[1].GetRateLimiter().Burst()).Should(Equal(50))
+
+			s, err = guard.GetService("api")
+			Expect(err).Should(BeNil())
+			Expect(s.RetryIntervalMs).Should(Equal(10))
+			Expect(s.HasRateLimiter()).Should(BeFalse())
+
+			t := s.GetTicket()
+			_, err = guard.CreateRequest(t, "GET", "/")
+			Expect(err).Should(BeNil())
+		})
+
+		It("Load from file", func() {
+			file := filepath.Join(GinkgoT().TempDir(), "config.json")
+			Expect(os.WriteFile(file, []byte(jsonConfig), 0644)).Should(BeNil())
+
+			guard, err := g.NewRestGuardFromFile(file)
+			Expect(err).Should(BeNil())
+			Expect(guard.GetUserAgent()).Should(Equal(
+				specs.NewConfig().UserAgent))
+			s, err := guard.GetService("api")
+			Expect(err).Should(BeNil())
+			Expect(s.Retries).Should(Equal(1))
+		})
+	})
+
+	Context("Validation", func() {
+
+		It("Report the invalid node", func() {
+			doc := `
+services:
+  api:
+    nodes:
+      - name: api1
+`
+			_, err := g.LoadConfig(strings.NewReader(doc), "yaml")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(Equal("service api, node api1: base_url is required"))
+		})
+
+		It("Report the invalid option", func() {
+			doc := `
+services:
+  api:
+    options:
+      rate_limiter: "abc"
+    nodes:
+      - name: api1
+        base_url: 127.0.0.1
+`
+			_, err := g.LoadConfig(strings.NewReader(doc), "yaml")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(HavePrefix("service api: "))
+		})
+
+		It("Report the invalid node rate limit", func() {
+			doc := `
+services:
+  api:
+    nodes:
+      - name: api1
+        base_url: 127.0.0.1
+        rate_limit: "10/d"
+`
+			_, err := g.LoadConfig(strings.NewReader(doc), "yaml")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(HavePrefix("service api, node api1: "))
+		})
+
+		It("Report the service without nodes", func() {
+			_, err := g.LoadConfig(strings.NewReader(`{"services": {"api": {}}}`), "json")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(Equal("service api: no nodes defined"))
+		})
+
+		It("Reject the unknown fields", func() {
+			doc := `
+services:
+  api:
+    retrys: 3
+    nodes:
+      - name: api1
+        base_url: 127.0.0.1
+`
+			_, err := g.LoadConfig(strings.NewReader(doc), "yaml")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(HavePrefix("service api: "))
+			Expect(err.Error()).Should(ContainSubstring("retrys"))
+
+			doc = `
+config:
+  user_agnt: "test-agent"
+`
+			_, err = g.LoadConfig(strings.NewReader(doc), "yaml")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(ContainSubstring("user_agnt"))
+
+			doc = `{"services": {"api": {"nodes": [
+  { "name": "api1", "base_url": "127.0.0.1", "wieght": 2 } ]}}}`
+			_, err = g.LoadConfig(strings.NewReader(doc), "json")
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(HavePrefix("service api: "))
+			Expect(err.Error()).Should(ContainSubstring("wieght"))
+		})
+
+		It("Reject unknown formats", func() {
+			_, err := g.LoadConfig(strings.NewReader(jsonConfig), "toml")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+})
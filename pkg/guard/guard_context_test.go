@@ -0,0 +1,134 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Context Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		node    *specs.RestNode
+		guard   *g.RestGuard
+		service *specs.RestService
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.SetAllowUnhandledRequests(true)
+		node = specs.NewRestNode("LocalServer", server.Addr(), false)
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(), node)).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Cancel on retry wait", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET", "/fail",
+				ghttp.RespondWith(http.StatusInternalServerError, "KO"))
+		})
+
+		It("Stop the retries loop", func() {
+			service.Retries = 5
+			service.RetryIntervalMs = 5000
+
+			t := service.GetTicket()
+			defer t.Rip()
+			_, err := guard.CreateRequest(t, "GET", "/fail")
+			Expect(err).Should(BeNil())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			errDo := guard.DoContext(ctx, t)
+
+			var ierr *g.InterruptedError
+			Expect(errors.As(errDo, &ierr)).Should(BeTrue())
+			Expect(ierr.Phase).Should(Equal(g.PhaseRetryWait))
+			// The attempt failed before the wait.
+			Expect(ierr.Attempt).Should(Equal(1))
+			Expect(ierr.Node).Should(Equal("LocalServer"))
+			Expect(errors.Is(errDo, context.DeadlineExceeded)).Should(BeTrue())
+			Expect(time.Since(start)).Should(BeNumerically("<", 2*time.Second))
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+	})
+
+	Context("Cancel on request", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("GET", "/slow",
+				func(w http.ResponseWriter, r *http.Request) {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(2 * time.Second):
+					}
+					w.WriteHeader(http.StatusOK)
+				})
+		})
+
+		It("Interrupt the running attempt", func() {
+			service.Retries = 3
+
+			t := service.GetTicket()
+			defer t.Rip()
+			_, err := guard.CreateRequest(t, "GET", "/slow")
+			Expect(err).Should(BeNil())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				cancel()
+			}()
+
+			errDo := guard.DoWithTimeoutContext(ctx, t, 10)
+
+			var ierr *g.InterruptedError
+			Expect(errors.As(errDo, &ierr)).Should(BeTrue())
+			Expect(ierr.Phase).Should(Equal(g.PhaseRequest))
+			Expect(ierr.Attempt).Should(Equal(1))
+			Expect(errors.Is(errDo, context.Canceled)).Should(BeTrue())
+			Expect(t.Retries).Should(Equal(0))
+		})
+
+		It("Stop the download", func() {
+			t := service.GetTicket()
+			defer t.Rip()
+			_, err := guard.CreateRequest(t, "GET", "/slow")
+			Expect(err).Should(BeNil())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			target := filepath.Join(GinkgoT().TempDir(), "artefact.bin")
+			artefact, errDo := guard.DoDownloadContext(ctx, t, target)
+			Expect(artefact).Should(BeNil())
+			Expect(errors.Is(errDo, context.Canceled)).Should(BeTrue())
+			Expect(server.ReceivedRequests()).Should(HaveLen(0))
+		})
+	})
+
+})
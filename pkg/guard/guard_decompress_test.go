@@ -0,0 +1,215 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/ulikunitz/xz"
+)
+
+// The output of: printf 'hello bzip2 index\n' | bzip2 -9
+const bzip2Index = "QlpoOTFBWSZTWT902xIAAARZgAAQQAAQABZlwFAgACIIyaYyEAAAY4gXX3OEs3Dou5IpwoSB+6bYkA=="
+
+var _ = Describe("Decompress Download Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		index   []byte
+		target  string
+	)
+
+	compress := func(format string, data []byte) []byte {
+		var buf bytes.Buffer
+		switch format {
+		case g.CompressionGzip:
+			w := gzip.NewWriter(&buf)
+			w.Write(data)
+			Expect(w.Close()).Should(BeNil())
+		case g.CompressionXz:
+			w, err := xz.NewWriter(&buf)
+			Expect(err).Should(BeNil())
+			w.Write(data)
+			Expect(w.Close()).Should(BeNil())
+		case g.CompressionZstd:
+			w, err := zstd.NewWriter(&buf)
+			Expect(err).Should(BeNil())
+			w.Write(data)
+			Expect(w.Close()).Should(BeNil())
+		}
+		return buf.Bytes()
+	}
+
+	serve := func(path, contentType string, data []byte) {
+		server.RouteToHandler("GET", path, func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.Header.Get("Accept-Encoding")).Should(Equal("identity"))
+			if contentType != "" {
+				w.Header().Set("Content-Type", contentType)
+			}
+			w.Write(data)
+		})
+	}
+
+	download := func(path string, opts *g.DownloadOptions) (*specs.RestArtefact, error) {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", path)
+		Expect(err).Should(BeNil())
+		return guard.DoDownloadWithOptions(context.Background(), t, target, opts)
+	}
+
+	expectArtefact := func(artefact *specs.RestArtefact, output, received []byte) {
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(data).Should(Equal(output))
+
+		Expect(artefact.Size).Should(Equal(int64(len(output))))
+		Expect(artefact.Md5).Should(Equal(fmt.Sprintf("%x", md5.Sum(output))))
+		Expect(artefact.Sha512).Should(Equal(fmt.Sprintf("%x", sha512.Sum512(output))))
+		Expect(artefact.Compressed).ShouldNot(BeNil())
+		Expect(artefact.Compressed.Size).Should(Equal(int64(len(received))))
+		Expect(artefact.Compressed.Md5).Should(Equal(fmt.Sprintf("%x", md5.Sum(received))))
+		Expect(artefact.Compressed.Sha512).Should(Equal(fmt.Sprintf("%x", sha512.Sum512(received))))
+		Expect(artefact.Compressed.Blake2b).ShouldNot(BeEmpty())
+	}
+
+	BeforeEach(func() {
+		var err error
+		index = []byte(strings.Repeat("package: app-misc/foo\nversion: 1.0\n", 512))
+		target = filepath.Join(GinkgoT().TempDir(), "index.yaml")
+
+		server = ghttp.NewServer()
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	DescribeTable("Select the format by extension",
+		func(format, path string) {
+			compressed := compress(format, index)
+			serve(path, "application/octet-stream", compressed)
+
+			artefact, err := download(path, &g.DownloadOptions{Decompress: g.DecompressAuto})
+			Expect(err).Should(BeNil())
+			expectArtefact(artefact, index, compressed)
+		},
+		Entry("gzip", g.CompressionGzip, "/index.yaml.gz"),
+		Entry("xz", g.CompressionXz, "/index.yaml.xz"),
+		Entry("zstd", g.CompressionZstd, "/index.yaml.zst"),
+	)
+
+	It("Decompress bzip2", func() {
+		compressed, err := base64.StdEncoding.DecodeString(bzip2Index)
+		Expect(err).Should(BeNil())
+		serve("/index.yaml.bz2", "", compressed)
+
+		artefact, err := download("/index.yaml.bz2", &g.DownloadOptions{Decompress: g.DecompressAuto})
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact, []byte("hello bzip2 index\n"), compressed)
+	})
+
+	It("Select the format by Content-Type", func() {
+		compressed := compress(g.CompressionXz, index)
+		serve("/index", "application/x-xz", compressed)
+
+		artefact, err := download("/index", &g.DownloadOptions{Decompress: g.DecompressAuto})
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact, index, compressed)
+	})
+
+	It("Use the format in input", func() {
+		compressed := compress(g.CompressionZstd, index)
+		serve("/index", "application/octet-stream", compressed)
+
+		artefact, err := download("/index", &g.DownloadOptions{Decompress: g.CompressionZstd})
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact, index, compressed)
+	})
+
+	It("Write the artefact as received without compression", func() {
+		serve("/index.yaml", "text/yaml", index)
+
+		artefact, err := download("/index.yaml", &g.DownloadOptions{
+			Decompress: g.DecompressAuto,
+			Expected: &specs.RestArtefact{
+				Compressed: &specs.RestArtefact{Md5: fmt.Sprintf("%x", md5.Sum(index))},
+			},
+		})
+		Expect(err).Should(BeNil())
+		Expect(artefact.Compressed).Should(BeNil())
+		Expect(artefact.Md5).Should(Equal(fmt.Sprintf("%x", md5.Sum(index))))
+	})
+
+	It("Verify the hashes of the stream received", func() {
+		compressed := compress(g.CompressionGzip, index)
+		serve("/index.yaml.gz", "", compressed)
+
+		artefact, err := download("/index.yaml.gz", &g.DownloadOptions{
+			Decompress: g.DecompressAuto,
+			Expected: &specs.RestArtefact{
+				Md5:        fmt.Sprintf("%x", md5.Sum(index)),
+				Compressed: &specs.RestArtefact{Sha512: fmt.Sprintf("%x", sha512.Sum512(compressed))},
+			},
+		})
+		Expect(err).Should(BeNil())
+		Expect(artefact.Path).Should(Equal(target))
+
+		_, err = download("/index.yaml.gz", &g.DownloadOptions{
+			Decompress: g.DecompressAuto,
+			Expected: &specs.RestArtefact{
+				Compressed: &specs.RestArtefact{Md5: fmt.Sprintf("%x", md5.Sum(index))},
+			},
+		})
+		var mismatchErr *g.ChecksumMismatchError
+		Expect(err).Should(BeAssignableToTypeOf(mismatchErr))
+		Expect(err.Error()).Should(ContainSubstring("compressed md5"))
+	})
+
+	It("Fail on invalid compressed data", func() {
+		serve("/index.yaml.xz", "", index)
+
+		_, err := download("/index.yaml.xz", &g.DownloadOptions{Decompress: g.DecompressAuto})
+		Expect(err).ShouldNot(BeNil())
+		Expect(err.Error()).Should(ContainSubstring("decompress xz"))
+		_, err = os.Stat(target)
+		Expect(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("Reject the invalid options", func() {
+		_, err := download("/index", &g.DownloadOptions{Decompress: "lz4"})
+		Expect(err).ShouldNot(BeNil())
+
+		_, err = download("/index", &g.DownloadOptions{Decompress: g.CompressionGzip, Resume: true})
+		Expect(err).ShouldNot(BeNil())
+		Expect(server.ReceivedRequests()).Should(BeEmpty())
+	})
+
+})
@@ -0,0 +1,125 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Health Check Tests", func() {
+
+	var (
+		server     *ghttp.Server
+		node       *specs.RestNode
+		guard      *g.RestGuard
+		service    *specs.RestService
+		statusCode atomic.Int32
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		statusCode.Store(http.StatusOK)
+		server.RouteToHandler("HEAD", "/health",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(int(statusCode.Load()))
+			})
+		node = specs.NewRestNode("LocalServer", server.Addr(), false)
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+
+		service = specs.NewRestService("local-tester")
+		service.HealthCheck = specs.NewRestHealthCheck("/health")
+		service.HealthCheck.Method = "HEAD"
+		service.HealthCheck.IntervalMs = 20
+		service.HealthCheck.ExpectedStatus = []int{200, 204}
+		service.HealthCheck.SuccessThreshold = 2
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(), node)).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		guard.StopHealthChecks()
+		server.Close()
+	})
+
+	It("Disable and re-enable the node", func() {
+		Expect(guard.StartHealthChecks()).Should(BeNil())
+		Expect(guard.StartHealthChecks()).ShouldNot(BeNil())
+
+		Eventually(guard.GetHealthStatus).Should(HaveLen(1))
+		Expect(node.IsHealthy()).Should(BeTrue())
+
+		statusCode.Store(http.StatusServiceUnavailable)
+		Eventually(node.IsHealthy, time.Second).Should(BeFalse())
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/")
+		Expect(err).ShouldNot(BeNil())
+
+		status := guard.GetHealthStatus()
+		Expect(status[0].Service).Should(Equal("local-tester"))
+		Expect(status[0].Node).Should(Equal("LocalServer"))
+		Expect(status[0].Healthy).Should(BeFalse())
+		Expect(status[0].LastStatusCode).Should(Equal(http.StatusServiceUnavailable))
+
+		statusCode.Store(http.StatusNoContent)
+		Eventually(node.IsHealthy, time.Second).Should(BeTrue())
+		Expect(node.IsActive()).Should(BeTrue())
+
+		guard.StopHealthChecks()
+		Expect(guard.GetHealthStatus()).Should(HaveLen(0))
+	})
+
+	It("Send the credentials of the node", func() {
+		node.Authenticator = &specs.BearerAuth{Token: "secret"}
+		server.RouteToHandler("HEAD", "/health",
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+		Expect(guard.StartHealthChecks()).Should(BeNil())
+		Eventually(func() int {
+			n := 0
+			for _, st := range guard.GetHealthStatus() {
+				if st.LastStatusCode == http.StatusOK {
+					n++
+				}
+			}
+			return n
+		}, time.Second).Should(Equal(1))
+		Consistently(node.IsHealthy, 100*time.Millisecond).Should(BeTrue())
+	})
+
+	It("Restore the unhealthy nodes on stop", func() {
+		statusCode.Store(http.StatusServiceUnavailable)
+		Expect(guard.StartHealthChecks()).Should(BeNil())
+		Eventually(node.IsHealthy, time.Second).Should(BeFalse())
+
+		guard.StopHealthChecks()
+		Expect(node.IsHealthy()).Should(BeTrue())
+
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/")
+		Expect(err).Should(BeNil())
+		Expect(t.Node).Should(Equal(node))
+	})
+
+})
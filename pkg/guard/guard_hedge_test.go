@@ -0,0 +1,224 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+// refreshAuth sends a new token after every Invalidate.
+type refreshAuth struct {
+	mutex sync.Mutex
+	n     int
+}
+
+func (a *refreshAuth) Authenticate(req *http.Request) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer token%d", a.n))
+	return nil
+}
+
+func (a *refreshAuth) Invalidate() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.n++
+}
+
+var _ = Describe("Hedged Requests Tests", func() {
+
+	var (
+		slow     *ghttp.Server
+		fast     *ghttp.Server
+		guard    *g.RestGuard
+		service  *specs.RestService
+		canceled chan bool
+	)
+
+	BeforeEach(func() {
+		var err error
+		canceled = make(chan bool, 1)
+		slow = ghttp.NewServer()
+		slow.RouteToHandler("GET", "/data", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+				canceled <- true
+			case <-time.After(500 * time.Millisecond):
+				w.Write([]byte("slow"))
+			}
+		})
+		fast = ghttp.NewServer()
+		fast.RouteToHandler("GET", "/data", ghttp.RespondWith(http.StatusOK, "fast"))
+		fast.RouteToHandler("POST", "/data", ghttp.RespondWith(http.StatusOK, "fast"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.HedgeDelayMs = 50
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("slow", slow.Addr(), false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("fast", fast.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		slow.Close()
+		fast.Close()
+	})
+
+	It("The hedged request wins", func() {
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/data")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("slow"))
+
+		start := time.Now()
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(time.Since(start)).Should(BeNumerically("<", 400*time.Millisecond))
+		Expect(t.Node.Name).Should(Equal("fast"))
+
+		data, err := io.ReadAll(t.Response.Body)
+		Expect(err).Should(BeNil())
+		Expect(string(data)).Should(Equal("fast"))
+		t.Rip()
+
+		Eventually(canceled).Should(Receive())
+		Eventually(service.GetHedgesInFlight).Should(Equal(0))
+	})
+
+	It("Run the callbacks with a closure for every request", func() {
+		t := service.GetTicket()
+		t.Closure = map[string]interface{}{"caller": true}
+		t.SetRequestCloseCb(func(ht *specs.RestTicket) {
+			ht.SetClosure("node", ht.Node.Name)
+			for i := 0; i < 100; i++ {
+				ht.SetClosure(fmt.Sprintf("key%d", i), i)
+			}
+		})
+		_, err := guard.CreateRequest(t, "GET", "/data")
+		Expect(err).Should(BeNil())
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("fast"))
+		t.Rip()
+
+		// The ticket contains the closure of the winner.
+		v, ok := t.GetClosure("node")
+		Expect(ok).Should(BeTrue())
+		Expect(v).Should(Equal("fast"))
+		_, ok = t.GetClosure("caller")
+		Expect(ok).Should(BeTrue())
+		Eventually(canceled).Should(Receive())
+	})
+
+	It("Respect the max hedges in flight", func() {
+		service.HedgeMaxInFlight = 1
+		Expect(service.AcquireHedge()).Should(BeTrue())
+		defer service.ReleaseHedge()
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/data")
+		Expect(err).Should(BeNil())
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("slow"))
+		Expect(fast.ReceivedRequests()).Should(HaveLen(0))
+		t.Rip()
+	})
+
+	It("Don't hedge not idempotent requests", func() {
+		t := service.GetTicket()
+		t.Node = service.GetNodes()[1]
+		_, err := guard.CreateRequest(t, "POST", "/data")
+		Expect(err).Should(BeNil())
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("fast"))
+		Expect(slow.ReceivedRequests()).Should(HaveLen(0))
+		t.Rip()
+	})
+
+	It("Failover after an invalid response", func() {
+		slow.RouteToHandler("GET", "/data", ghttp.RespondWith(http.StatusBadGateway, "KO"))
+		service.Retries = 1
+		service.HedgeDelayMs = 1000
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/data")
+		Expect(err).Should(BeNil())
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("fast"))
+		Expect(t.Retries).Should(Equal(1))
+		t.Rip()
+	})
+
+	It("Wait the Retry-After on the same node", func() {
+		var calls atomic.Int32
+		slow.RouteToHandler("GET", "/data", func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("slow"))
+		})
+		service.Retries = 1
+		service.HedgeDelayMs = 5000
+		service.RetryAfterMode = specs.RetryAfterWait
+
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/data")
+		Expect(err).Should(BeNil())
+		start := time.Now()
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(time.Since(start)).Should(BeNumerically(">=", time.Second))
+		Expect(t.Node.Name).Should(Equal("slow"))
+		Expect(t.Retries).Should(Equal(1))
+		Expect(fast.ReceivedRequests()).Should(HaveLen(0))
+	})
+
+	It("Refresh the credentials once after a 401", func() {
+		auth := &refreshAuth{}
+		service.Authenticator = auth
+		service.HedgeDelayMs = 5000
+		slow.RouteToHandler("GET", "/data", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("slow"))
+		})
+
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/data")
+		Expect(err).Should(BeNil())
+		Expect(guard.DoHedged(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("slow"))
+		Expect(t.Retries).Should(Equal(0))
+		Expect(slow.ReceivedRequests()).Should(HaveLen(2))
+		Expect(fast.ReceivedRequests()).Should(HaveLen(0))
+	})
+
+	It("Compute the delay from the latencies", func() {
+		service.HedgePercentile = 90
+		for i := 1; i <= 10; i++ {
+			service.ObserveLatency(time.Duration(i) * time.Millisecond)
+		}
+		Expect(service.GetHedgeDelay()).Should(Equal(9 * time.Millisecond))
+	})
+
+})
@@ -0,0 +1,96 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Metrics Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "0123456789"))
+		server.RouteToHandler("GET", "/ko", ghttp.RespondWith(http.StatusNotFound, "KO"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		service.SetOption(specs.ServiceRateLimiter, "100")
+		Expect(service.SetRateLimiter()).Should(BeNil())
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("failed", "127.0.0.1:10000", false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Expose the counters of the nodes", func() {
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		_, err = guard.DoDownload(t, filepath.Join(GinkgoT().TempDir(), "file"))
+		Expect(err).Should(BeNil())
+		t.Rip()
+
+		t = service.GetTicket()
+		t.Node = service.GetNodes()[1]
+		_, err = guard.CreateRequest(t, "GET", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		t.Rip()
+
+		rec := httptest.NewRecorder()
+		guard.Metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(rec.Header().Get("Content-Type")).Should(HavePrefix("text/plain"))
+		data, err := io.ReadAll(rec.Body)
+		Expect(err).Should(BeNil())
+
+		out := string(data)
+		Expect(out).Should(ContainSubstring(
+			`restguard_requests_total{service="local-tester",node="LocalServer",code="2xx"} 1`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_requests_total{service="local-tester",node="LocalServer",code="4xx"} 2`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_transport_errors_total{service="local-tester",node="failed"} 1`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_retries_total{service="local-tester",node="failed"} 1`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_retries_total{service="local-tester",node="LocalServer"} 1`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_validator_rejections_total{service="local-tester",node="LocalServer"} 2`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_request_duration_seconds_count{service="local-tester",node="LocalServer"} 3`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_ratelimiter_wait_seconds_count{service="local-tester"} 4`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_download_bytes_total{service="local-tester",node="LocalServer"} 10`))
+		Expect(out).Should(ContainSubstring(
+			`restguard_download_duration_seconds_bucket{service="local-tester",node="LocalServer",le="+Inf"} 1`))
+	})
+
+})
@@ -0,0 +1,105 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"net/http"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+type recorderMiddleware struct {
+	name   string
+	events *[]string
+}
+
+func (m *recorderMiddleware) WrapAttempt(next specs.AttemptFunc) specs.AttemptFunc {
+	return func(t *specs.RestTicket, req *http.Request) (*http.Response, error) {
+		*m.events = append(*m.events, m.name+":attempt:"+t.Node.Name)
+		return next(t, req)
+	}
+}
+
+func (m *recorderMiddleware) WrapTicket(next specs.TicketFunc) specs.TicketFunc {
+	return func(ctx context.Context, t *specs.RestTicket) error {
+		*m.events = append(*m.events, m.name+":start")
+		err := next(ctx, t)
+		*m.events = append(*m.events, m.name+":end")
+		return err
+	}
+}
+
+var _ = Describe("Middleware Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		events  []string
+	)
+
+	BeforeEach(func() {
+		var err error
+		events = []string{}
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/auth",
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "Bearer token"),
+				ghttp.RespondWith(http.StatusOK, "OK"),
+			))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("failed", "127.0.0.1:10000", false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Compose global and service middlewares", func() {
+		guard.Use(&recorderMiddleware{name: "global", events: &events})
+		service.Use(
+			&recorderMiddleware{name: "service", events: &events},
+			specs.MiddlewareFunc(func(next specs.AttemptFunc) specs.AttemptFunc {
+				return func(t *specs.RestTicket, req *http.Request) (*http.Response, error) {
+					req.Header.Set("Authorization", "Bearer token")
+					return next(t, req)
+				}
+			}),
+		)
+
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/auth")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(t.Retries).Should(Equal(1))
+
+		Expect(events).Should(Equal([]string{
+			"global:start",
+			"service:start",
+			"global:attempt:failed",
+			"service:attempt:failed",
+			"global:attempt:LocalServer",
+			"service:attempt:LocalServer",
+			"service:end",
+			"global:end",
+		}))
+	})
+
+})
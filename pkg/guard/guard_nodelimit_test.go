@@ -0,0 +1,80 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Node Rate Limiter Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		limited *specs.RestNode
+		free    *specs.RestNode
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.SetOption(specs.ServiceRateLimiter, "1000/s")
+		Expect(service.SetRateLimiter()).Should(BeNil())
+		guard.AddService(service.GetName(), service)
+
+		limited = specs.NewRestNode("limited", server.Addr(), false)
+		limited.RateLimit = "1/h"
+		Expect(limited.SetRateLimiter()).Should(BeNil())
+		free = specs.NewRestNode("free", server.Addr(), false)
+		Expect(guard.AddRestNode(service.GetName(), limited)).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(), free)).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	doTicket := func(ctx context.Context, n *specs.RestNode) error {
+		t := service.GetTicket()
+		defer t.Rip()
+		t.Node = n
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		return guard.DoContext(ctx, t)
+	}
+
+	It("Throttle only the limited node", func() {
+		Expect(doTicket(context.Background(), limited)).Should(BeNil())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		// The wait exceeds the deadline of the context.
+		err := doTicket(ctx, limited)
+		Expect(err).ShouldNot(BeNil())
+		Expect(err.Error()).Should(ContainSubstring("rate limiting"))
+
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			Expect(doTicket(context.Background(), free)).Should(BeNil())
+		}
+		Expect(time.Since(start)).Should(BeNumerically("<", time.Second))
+	})
+
+})
@@ -0,0 +1,132 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Download Progress Tests", func() {
+
+	var (
+		broken  *ghttp.Server
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		content []byte
+		target  string
+		events  []g.DownloadProgress
+		opts    *g.DownloadOptions
+	)
+
+	BeforeEach(func() {
+		var err error
+		content = bytes.Repeat([]byte("0123456789abcdef"), 1024)
+		target = filepath.Join(GinkgoT().TempDir(), "artefact.bin")
+		events = []g.DownloadProgress{}
+		opts = &g.DownloadOptions{
+			ProgressCb: func(p g.DownloadProgress) {
+				events = append(events, p)
+			},
+			ProgressInterval: 10 * time.Millisecond,
+		}
+
+		broken = ghttp.NewServer()
+		broken.RouteToHandler("GET", "/file", ghttp.RespondWith(http.StatusInternalServerError, nil))
+
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/file", func(w http.ResponseWriter, r *http.Request) {
+			// Send the file in chunks slowly.
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			chunk := len(content) / 8
+			for i := 0; i < len(content); i += chunk {
+				w.Write(content[i : i+chunk])
+				w.(http.Flusher).Flush()
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		service.RetryIntervalMs = 0
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("broken", broken.Addr(), false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		broken.Close()
+		server.Close()
+	})
+
+	It("Report the progress and the retries", func() {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+
+		artefact, err := guard.DoDownloadWithOptions(context.Background(), t, target, opts)
+		Expect(err).Should(BeNil())
+		Expect(artefact.Size).Should(Equal(int64(len(content))))
+
+		Expect(len(events)).Should(BeNumerically(">=", 3))
+		Expect(events[0].Event).Should(Equal(g.DownloadEventRetry))
+		Expect(events[0].Node).Should(Equal("broken"))
+		Expect(events[0].Retries).Should(Equal(1))
+
+		progress := events[1 : len(events)-1]
+		Expect(progress).ShouldNot(BeEmpty())
+		written := int64(0)
+		for _, p := range progress {
+			Expect(p.Event).Should(Equal(g.DownloadEventProgress))
+			Expect(p.TicketId).Should(Equal(t.Id))
+			Expect(p.Path).Should(Equal(target))
+			Expect(p.Node).Should(Equal("LocalServer"))
+			Expect(p.Total).Should(Equal(int64(len(content))))
+			Expect(p.Written).Should(BeNumerically(">=", written))
+			Expect(p.AvgRate).Should(BeNumerically(">", 0))
+			Expect(p.Eta).Should(BeNumerically(">=", 0))
+			written = p.Written
+		}
+
+		last := events[len(events)-1]
+		Expect(last.Event).Should(Equal(g.DownloadEventDone))
+		Expect(last.Written).Should(Equal(last.Total))
+		Expect(last.Eta).Should(Equal(time.Duration(0)))
+	})
+
+	It("Throttle the updates", func() {
+		opts.ProgressInterval = time.Hour
+		service.Nodes = service.Nodes[1:]
+
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+
+		_, err = guard.DoDownloadWithOptions(context.Background(), t, target, opts)
+		Expect(err).Should(BeNil())
+		Expect(events).Should(HaveLen(1))
+		Expect(events[0].Event).Should(Equal(g.DownloadEventDone))
+		Expect(events[0].Total).Should(Equal(int64(len(content))))
+	})
+
+})
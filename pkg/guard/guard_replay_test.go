@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Replayable Body Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		bodies  []string
+	)
+
+	// Record the body received and fail the first attempt.
+	recordBody := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			data, err := io.ReadAll(r.Body)
+			Expect(err).Should(BeNil())
+			Expect(r.ContentLength).Should(Equal(int64(len(data))))
+			bodies = append(bodies, string(data))
+			w.WriteHeader(code)
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		bodies = []string{}
+		server = ghttp.NewServer()
+		server.AppendHandlers(
+			recordBody(http.StatusInternalServerError),
+			recordBody(http.StatusCreated),
+		)
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Replay a JSON body", func() {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "POST", "/orders")
+		Expect(err).Should(BeNil())
+		Expect(t.SetBodyJSON(map[string]string{"item": "book"})).Should(BeNil())
+		Expect(t.Request.Header.Get("Content-Type")).Should(Equal("application/json"))
+
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(bodies).Should(Equal([]string{
+			`{"item":"book"}`, `{"item":"book"}`,
+		}))
+		Expect(server.ReceivedRequests()[1].Header.Get("Content-Type")).Should(
+			Equal("application/json"))
+	})
+
+	It("Replay a file body set before the request", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "body")
+		Expect(os.WriteFile(file, []byte("file content"), 0644)).Should(BeNil())
+
+		t := service.GetTicket()
+		defer t.Rip()
+		Expect(t.SetBodyFile(file)).Should(BeNil())
+		req, err := guard.CreateRequest(t, "PUT", "/file")
+		Expect(err).Should(BeNil())
+		Expect(req.ContentLength).Should(Equal(int64(12)))
+		Expect(req.GetBody).ShouldNot(BeNil())
+
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(bodies).Should(Equal([]string{"file content", "file content"}))
+	})
+
+	It("Send the size of the file on every attempt", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "body")
+		Expect(os.WriteFile(file, []byte("file content"), 0644)).Should(BeNil())
+		server.SetHandler(0, func(w http.ResponseWriter, r *http.Request) {
+			recordBody(http.StatusInternalServerError)(w, r)
+			Expect(os.WriteFile(file, []byte("new file content"), 0644)).Should(BeNil())
+		})
+
+		t := service.GetTicket()
+		defer t.Rip()
+		Expect(t.SetBodyFile(file)).Should(BeNil())
+		_, err := guard.CreateRequest(t, "PUT", "/file")
+		Expect(err).Should(BeNil())
+
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(bodies).Should(Equal([]string{"file content", "new file content"}))
+	})
+
+	It("Close the body of the request not sent", func() {
+		file := filepath.Join(GinkgoT().TempDir(), "body")
+		Expect(os.WriteFile(file, []byte("file content"), 0644)).Should(BeNil())
+
+		t := service.GetTicket()
+		Expect(t.SetBodyFile(file)).Should(BeNil())
+		req, err := guard.CreateRequest(t, "PUT", "/file")
+		Expect(err).Should(BeNil())
+		t.Rip()
+
+		_, err = req.Body.Read(make([]byte, 1))
+		Expect(errors.Is(err, os.ErrClosed)).Should(BeTrue())
+	})
+
+	It("Spool a not seekable body", func() {
+		defer func(v int64) { specs.BodySpoolMemoryLimit = v }(specs.BodySpoolMemoryLimit)
+		specs.BodySpoolMemoryLimit = 4
+		service.BodySpoolDir = GinkgoT().TempDir()
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "POST", "/stream")
+		Expect(err).Should(BeNil())
+		Expect(t.SetBodyReader(io.MultiReader(
+			strings.NewReader("streaming "), strings.NewReader("body")))).Should(BeNil())
+
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(bodies).Should(Equal([]string{"streaming body", "streaming body"}))
+
+		spooled, err := filepath.Glob(filepath.Join(service.BodySpoolDir, "restguard-body-*"))
+		Expect(err).Should(BeNil())
+		Expect(spooled).Should(HaveLen(1))
+		t.Rip()
+		for _, f := range spooled {
+			_, err := os.Stat(f)
+			Expect(os.IsNotExist(err)).Should(BeTrue())
+		}
+	})
+
+	It("Replay the body on redirect", func() {
+		server.SetHandler(0, ghttp.RespondWith(http.StatusTemporaryRedirect, "",
+			http.Header{"Location": []string{"/moved"}}))
+
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "POST", "/orig")
+		Expect(err).Should(BeNil())
+		Expect(t.SetBodyReader(strings.NewReader("redirected"))).Should(BeNil())
+
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(bodies).Should(Equal([]string{"redirected"}))
+		Expect(server.ReceivedRequests()[1].URL.Path).Should(Equal("/moved"))
+	})
+
+})
@@ -0,0 +1,183 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"golang.org/x/crypto/blake2b"
+)
+
+var _ = Describe("Resumable Download Tests", func() {
+
+	var (
+		server   *ghttp.Server
+		guard    *g.RestGuard
+		service  *specs.RestService
+		content  []byte
+		calls    atomic.Int32
+		failures int32
+		target   string
+	)
+
+	BeforeEach(func() {
+		var err error
+		content = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+		calls.Store(0)
+		failures = 1
+		target = filepath.Join(GinkgoT().TempDir(), "artefact.bin")
+
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/file",
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				if calls.Add(1) <= failures {
+					// Send only the first half of the file and close
+					// the connection.
+					conn, buf, err := w.(http.Hijacker).Hijack()
+					Expect(err).Should(BeNil())
+					defer conn.Close()
+					buf.WriteString("HTTP/1.1 200 OK\r\nETag: \"v1\"\r\n")
+					buf.WriteString("Content-Length: " + strconv.Itoa(len(content)) + "\r\n\r\n")
+					buf.Write(content[:len(content)/2])
+					buf.Flush()
+					return
+				}
+				http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+			})
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	checkArtefact := func(artefact *specs.RestArtefact) {
+		Expect(artefact).ShouldNot(BeNil())
+		Expect(artefact.Path).Should(Equal(target))
+		Expect(artefact.Size).Should(Equal(int64(len(content))))
+		Expect(artefact.Md5).Should(Equal(fmt.Sprintf("%x", md5.Sum(content))))
+		Expect(artefact.Sha512).Should(Equal(fmt.Sprintf("%x", sha512.Sum512(content))))
+		Expect(artefact.Blake2b).Should(Equal(fmt.Sprintf("%x", blake2b.Sum512(content))))
+
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(data).Should(Equal(content))
+		Expect(target + ".part").ShouldNot(BeAnExistingFile())
+		Expect(target + ".part.info").ShouldNot(BeAnExistingFile())
+	}
+
+	download := func() (*specs.RestArtefact, error) {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+		return guard.DoDownloadWithOptions(context.Background(), t, target,
+			&g.DownloadOptions{Resume: true})
+	}
+
+	It("Resume on retry", func() {
+		service.Retries = 1
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		checkArtefact(artefact)
+
+		reqs := server.ReceivedRequests()
+		Expect(reqs).Should(HaveLen(2))
+		Expect(reqs[1].Header.Get("Range")).Should(Equal(
+			fmt.Sprintf("bytes=%d-", len(content)/2)))
+		Expect(reqs[1].Header.Get("If-Range")).Should(Equal(`"v1"`))
+	})
+
+	It("Resume on a later call", func() {
+		artefact, err := download()
+		Expect(err).ShouldNot(BeNil())
+		Expect(artefact).Should(BeNil())
+		Expect(target + ".part").Should(BeAnExistingFile())
+
+		artefact, err = download()
+		Expect(err).Should(BeNil())
+		checkArtefact(artefact)
+		Expect(server.ReceivedRequests()[1].Header.Get("Range")).ShouldNot(BeEmpty())
+	})
+
+	It("Restart on changed artefact", func() {
+		failures = 0
+		Expect(os.WriteFile(target+".part", []byte("garbage"), 0644)).Should(BeNil())
+		Expect(os.WriteFile(target+".part.info",
+			[]byte(`{"path":"/file","etag":"\"v0\""}`), 0644)).Should(BeNil())
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		checkArtefact(artefact)
+		Expect(server.ReceivedRequests()[0].Header.Get("If-Range")).Should(Equal(`"v0"`))
+	})
+
+	It("Complete the download with the partial file already complete", func() {
+		failures = 0
+		Expect(os.WriteFile(target+".part", content, 0644)).Should(BeNil())
+		Expect(os.WriteFile(target+".part.info",
+			[]byte(`{"path":"/file","etag":"\"v1\""}`), 0644)).Should(BeNil())
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		checkArtefact(artefact)
+
+		reqs := server.ReceivedRequests()
+		Expect(reqs).Should(HaveLen(1))
+		Expect(reqs[0].Header.Get("Range")).Should(Equal(
+			fmt.Sprintf("bytes=%d-", len(content))))
+	})
+
+	It("Validate the Range requests of the caller", func() {
+		failures = 0
+		validated := 0
+		service.RespValidatorCb = func(t *specs.RestTicket) (bool, error) {
+			validated++
+			return t.Response.StatusCode == http.StatusPartialContent, nil
+		}
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+		t.Request.Header.Set("Range", "bytes=0-9")
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(t.Response.StatusCode).Should(Equal(http.StatusPartialContent))
+		t.Rip()
+		Expect(validated).Should(Equal(1))
+
+		// The 416 is not a success without the validator.
+		t = service.GetTicket()
+		defer t.Rip()
+		_, err = guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+		t.Request.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(content)))
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		Expect(validated).Should(Equal(2))
+	})
+
+})
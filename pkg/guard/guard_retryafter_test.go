@@ -0,0 +1,160 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Retry-After Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		server2 *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+	)
+
+	throttled := func(code int, after string) http.HandlerFunc {
+		return ghttp.RespondWith(code, "KO", http.Header{
+			"Retry-After": []string{after},
+		})
+	}
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server2 = ghttp.NewServer()
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("node1", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		server2.Close()
+	})
+
+	It("Parse the header", func() {
+		resp := &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": []string{"3"}},
+		}
+		d, ok := specs.ParseRetryAfter(resp)
+		Expect(ok).Should(BeTrue())
+		Expect(d).Should(Equal(3 * time.Second))
+
+		resp.Header.Set("Retry-After",
+			time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		d, ok = specs.ParseRetryAfter(resp)
+		Expect(ok).Should(BeTrue())
+		Expect(d).Should(BeNumerically("~", time.Hour, 2*time.Second))
+
+		resp.StatusCode = http.StatusNotFound
+		_, ok = specs.ParseRetryAfter(resp)
+		Expect(ok).Should(BeFalse())
+	})
+
+	It("Wait and retry on the same node", func() {
+		service.SetOption(specs.ServiceRetryAfterMode, specs.RetryAfterWait)
+		Expect(service.SetRetryAfter()).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("node2", server2.Addr(), false))).Should(BeNil())
+
+		server.AppendHandlers(
+			throttled(http.StatusTooManyRequests, "1"),
+			ghttp.RespondWith(http.StatusOK, "OK"),
+		)
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+
+		start := time.Now()
+		err = guard.Do(t)
+		Expect(err).Should(BeNil())
+		Expect(time.Since(start)).Should(BeNumerically(">=", time.Second))
+		Expect(t.Node.Name).Should(Equal("node1"))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+		Expect(server2.ReceivedRequests()).Should(HaveLen(0))
+		t.Rip()
+	})
+
+	It("Cooldown the node for all the tickets", func() {
+		service.SetOption(specs.ServiceRetryAfterMode, specs.RetryAfterCooldown)
+		Expect(service.SetRetryAfter()).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("node2", server2.Addr(), false))).Should(BeNil())
+
+		server.AppendHandlers(throttled(http.StatusServiceUnavailable, "60"))
+		server2.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("node2"))
+		t.Rip()
+
+		node1 := service.GetNodes()[0]
+		Expect(node1.IsCoolingDown()).Should(BeTrue())
+		Expect(node1.GetCooldown()).Should(BeNumerically(">", 50*time.Second))
+
+		// The next ticket skips the node cooling-down.
+		t = service.GetTicket()
+		_, err = guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("node2"))
+		Expect(guard.Do(t)).Should(BeNil())
+		t.Rip()
+
+		Expect(server.ReceivedRequests()).Should(HaveLen(1))
+	})
+
+	It("Fail fast over the max interval", func() {
+		service.RetryAfterMode = specs.RetryAfterWait
+		service.RetryAfterMaxMs = 500
+		Expect(service.SetRetryAfter()).Should(BeNil())
+
+		server.AppendHandlers(throttled(http.StatusTooManyRequests, "120"))
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+
+		start := time.Now()
+		err = guard.Do(t)
+		Expect(err).ShouldNot(BeNil())
+		Expect(time.Since(start)).Should(BeNumerically("<", time.Second))
+
+		var raErr *g.RetryAfterError
+		Expect(errors.As(err, &raErr)).Should(BeTrue())
+		Expect(raErr.Node).Should(Equal("node1"))
+		Expect(raErr.Delay).Should(Equal(120 * time.Second))
+		Expect(raErr.Max).Should(Equal(500 * time.Millisecond))
+		Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		t.Rip()
+	})
+
+	It("Reject an invalid mode", func() {
+		service.SetOption(specs.ServiceRetryAfterMode, "sleep")
+		Expect(service.SetRetryAfter()).ShouldNot(BeNil())
+	})
+
+})
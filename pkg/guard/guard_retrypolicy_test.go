@@ -0,0 +1,132 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"net/http"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Retry Policy Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.RouteToHandler("POST", "/ko", ghttp.RespondWith(http.StatusInternalServerError, "KO"))
+		server.RouteToHandler("GET", "/ko", ghttp.RespondWith(http.StatusInternalServerError, "KO"))
+		server.RouteToHandler("POST", "/ok", ghttp.RespondWith(http.StatusCreated, "OK"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 2
+		service.RetryIntervalMs = 0
+		guard.AddService(service.GetName(), service)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	addServerNode := func() {
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	}
+
+	It("Retry only idempotent methods", func() {
+		service.SetOption(specs.ServiceRetryPolicy, specs.RetryPolicyIdempotent)
+		Expect(service.SetRetryPolicy()).Should(BeNil())
+		addServerNode()
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "POST", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		Expect(t.Retries).Should(Equal(0))
+		Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		t.Rip()
+
+		t = service.GetTicket()
+		_, err = guard.CreateRequest(t, "GET", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		Expect(server.ReceivedRequests()).Should(HaveLen(4))
+		t.Rip()
+	})
+
+	It("Retry not idempotent methods only on connection errors", func() {
+		service.RetryPolicy = specs.RetryPolicyConnectErrors
+		Expect(service.SetRetryPolicy()).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("failed", "127.0.0.1:10000", false))).Should(BeNil())
+		addServerNode()
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "POST", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("failed"))
+		Expect(guard.Do(t)).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("LocalServer"))
+		t.Rip()
+
+		t = service.GetTicket()
+		t.Node = service.GetNodes()[1]
+		_, err = guard.CreateRequest(t, "POST", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		Expect(t.Retries).Should(Equal(0))
+		Expect(server.ReceivedRequests()).Should(HaveLen(2))
+		t.Rip()
+	})
+
+	It("Retry always by default", func() {
+		addServerNode()
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "POST", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		Expect(server.ReceivedRequests()).Should(HaveLen(3))
+		t.Rip()
+	})
+
+	It("Reuse the Idempotency-Key on all the attempts", func() {
+		service.SetOption(specs.ServiceRetryPolicy, specs.RetryPolicyIdempotent)
+		service.SetOption(specs.ServiceIdempotencyKey, "true")
+		Expect(service.SetRetryPolicy()).Should(BeNil())
+		Expect(service.IdempotencyKey).Should(BeTrue())
+		addServerNode()
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "POST", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+		t.Rip()
+
+		reqs := server.ReceivedRequests()
+		Expect(reqs).Should(HaveLen(3))
+		for _, r := range reqs {
+			Expect(r.Header.Get(specs.IdempotencyKeyHeader)).Should(Equal(t.Id))
+		}
+	})
+
+	It("Reject an invalid policy", func() {
+		service.RetryPolicy = "never"
+		Expect(service.SetRetryPolicy()).ShouldNot(BeNil())
+	})
+
+})
@@ -0,0 +1,246 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha512"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"golang.org/x/crypto/blake2b"
+)
+
+var _ = Describe("Segmented Download Tests", func() {
+
+	var (
+		mirror1 *ghttp.Server
+		mirror2 *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		content []byte
+		target  string
+		opts    *g.DownloadOptions
+	)
+
+	serveRanges := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "artefact.bin", time.Time{}, bytes.NewReader(content))
+	}
+
+	BeforeEach(func() {
+		var err error
+		content = make([]byte, 0, 64*1024)
+		for i := 0; len(content) < 64*1024; i++ {
+			content = append(content, []byte(fmt.Sprintf("%08d", i))...)
+		}
+		target = filepath.Join(GinkgoT().TempDir(), "artefact.bin")
+		opts = &g.DownloadOptions{
+			Segments:       4,
+			SegmentMinSize: 4096,
+		}
+
+		mirror1 = ghttp.NewServer()
+		mirror1.RouteToHandler("GET", "/file", serveRanges)
+		mirror2 = ghttp.NewServer()
+		mirror2.RouteToHandler("GET", "/file", serveRanges)
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		service.Retries = 1
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("mirror1", mirror1.Addr(), false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("mirror2", mirror2.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		mirror1.Close()
+		mirror2.Close()
+	})
+
+	download := func() (*specs.RestArtefact, error) {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+		return guard.DoDownloadWithOptions(context.Background(), t, target, opts)
+	}
+
+	expectArtefact := func(artefact *specs.RestArtefact) {
+		data, err := os.ReadFile(target)
+		Expect(err).Should(BeNil())
+		Expect(data).Should(Equal(content))
+
+		bhash, _ := blake2b.New512([]byte{})
+		bhash.Write(content)
+		Expect(artefact.Size).Should(Equal(int64(len(content))))
+		Expect(artefact.Md5).Should(Equal(fmt.Sprintf("%x", md5.Sum(content))))
+		Expect(artefact.Sha512).Should(Equal(fmt.Sprintf("%x", sha512.Sum512(content))))
+		Expect(artefact.Blake2b).Should(Equal(fmt.Sprintf("%x", bhash.Sum(nil))))
+	}
+
+	rangeRequests := func(s *ghttp.Server) int {
+		ans := 0
+		for _, r := range s.ReceivedRequests() {
+			if v := r.Header.Get("Range"); v != "" && v != "bytes=0-0" {
+				ans++
+			}
+		}
+		return ans
+	}
+
+	It("Download the segments from all the nodes", func() {
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact)
+
+		Expect(rangeRequests(mirror1) + rangeRequests(mirror2)).Should(Equal(4))
+		Expect(rangeRequests(mirror1)).Should(BeNumerically(">", 0))
+		Expect(rangeRequests(mirror2)).Should(BeNumerically(">", 0))
+		// The first request of the other node is without validator.
+		for _, r := range mirror1.ReceivedRequests() {
+			if r.Header.Get("Range") != "bytes=0-0" {
+				Expect(r.Header.Get("If-Range")).Should(Equal(`"v1"`))
+			}
+		}
+		for _, r := range mirror2.ReceivedRequests() {
+			Expect(r.Header.Get("If-Range")).Should(BeElementOf("", `"v1"`))
+		}
+	})
+
+	It("Share a slot of the bulkhead between the segments", func() {
+		service.BulkheadMaxConcurrent = 2
+		Expect(service.SetBulkhead()).Should(BeNil())
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact)
+		Expect(rangeRequests(mirror1) + rangeRequests(mirror2)).Should(Equal(4))
+		Expect(service.GetBulkhead().GetInFlight()).Should(Equal(0))
+	})
+
+	It("Send the credentials of the node of every segment", func() {
+		service.GetNodes()[0].Authenticator = &specs.BearerAuth{Token: "secret-for-mirror1"}
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact)
+
+		Expect(rangeRequests(mirror2)).Should(BeNumerically(">", 0))
+		for _, r := range mirror1.ReceivedRequests() {
+			Expect(r.Header.Get("Authorization")).Should(Equal("Bearer secret-for-mirror1"))
+		}
+		for _, r := range mirror2.ReceivedRequests() {
+			Expect(r.Header.Get("Authorization")).Should(BeEmpty())
+		}
+	})
+
+	It("Reassign the failed segments to another node", func() {
+		var broken atomic.Int32
+		mirror2.RouteToHandler("GET", "/file", func(w http.ResponseWriter, r *http.Request) {
+			v := r.Header.Get("Range")
+			if v == "bytes=0-0" {
+				serveRanges(w, r)
+				return
+			}
+			broken.Add(1)
+			// Send half of the segment and close the connection.
+			var start, end int
+			fmt.Sscanf(strings.TrimPrefix(v, "bytes="), "%d-%d", &start, &end)
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			Expect(err).Should(BeNil())
+			defer conn.Close()
+			buf.WriteString("HTTP/1.1 206 Partial Content\r\n")
+			buf.WriteString(fmt.Sprintf("Content-Range: bytes %d-%d/%d\r\n", start, end, len(content)))
+			buf.WriteString("Content-Length: " + strconv.Itoa(end-start+1) + "\r\n\r\n")
+			buf.Write(content[start : start+(end-start+1)/2])
+			buf.Flush()
+		})
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact)
+		Expect(broken.Load()).Should(BeNumerically(">", 0))
+
+		// The segments continue from the bytes received.
+		resumed := 0
+		for _, r := range mirror1.ReceivedRequests() {
+			var start, end int
+			fmt.Sscanf(strings.TrimPrefix(r.Header.Get("Range"), "bytes="), "%d-%d", &start, &end)
+			if start%16384 == 16384/2 {
+				resumed++
+			}
+		}
+		Expect(resumed).Should(Equal(int(broken.Load())))
+	})
+
+	It("Download with a single request without ranges", func() {
+		noRanges := func(w http.ResponseWriter, r *http.Request) {
+			w.Write(content)
+		}
+		mirror1.RouteToHandler("GET", "/file", noRanges)
+		mirror2.RouteToHandler("GET", "/file", noRanges)
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact)
+		Expect(len(mirror1.ReceivedRequests()) + len(mirror2.ReceivedRequests())).Should(Equal(2))
+	})
+
+	It("Download from the nodes with other validators", func() {
+		mirror2.RouteToHandler("GET", "/file", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"v2"`)
+			http.ServeContent(w, r, "artefact.bin", time.Time{}, bytes.NewReader(content))
+		})
+
+		artefact, err := download()
+		Expect(err).Should(BeNil())
+		expectArtefact(artefact)
+		Expect(rangeRequests(mirror1) + rangeRequests(mirror2)).Should(Equal(4))
+		Expect(rangeRequests(mirror2)).Should(BeNumerically(">", 0))
+		for _, r := range mirror2.ReceivedRequests() {
+			Expect(r.Header.Get("If-Range")).Should(BeElementOf("", `"v2"`))
+		}
+	})
+
+	It("Fail when the artefact is changed on all the nodes", func() {
+		var probed atomic.Bool
+		changed := func(w http.ResponseWriter, r *http.Request) {
+			if probed.CompareAndSwap(false, true) {
+				serveRanges(w, r)
+				return
+			}
+			w.Header().Set("ETag", `"v2"`)
+			http.ServeContent(w, r, "artefact.bin", time.Time{},
+				bytes.NewReader(append(content, []byte("v2")...)))
+		}
+		mirror1.RouteToHandler("GET", "/file", changed)
+		mirror2.RouteToHandler("GET", "/file", changed)
+
+		_, err := download()
+		Expect(err).ShouldNot(BeNil())
+		Expect(err.Error()).Should(ContainSubstring("changed"))
+		_, err = os.Stat(target)
+		Expect(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+})
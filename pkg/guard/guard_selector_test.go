@@ -0,0 +1,84 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"net/http"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Node Selector Tests", func() {
+
+	var (
+		server1, server2 *ghttp.Server
+		guard            *g.RestGuard
+		service          *specs.RestService
+	)
+
+	BeforeEach(func() {
+		var err error
+		server1 = ghttp.NewServer()
+		server2 = ghttp.NewServer()
+		server1.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+		server2.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+
+		service = specs.NewRestService("local-tester")
+		service.Retries = 2
+		service.Selector = specs.SelectorRoundRobin
+		Expect(service.SetSelector()).Should(BeNil())
+		guard.AddService(service.GetName(), service)
+	})
+
+	AfterEach(func() {
+		server1.Close()
+		server2.Close()
+	})
+
+	It("Spread the first attempts between the nodes", func() {
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("n1", server1.Addr(), false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("n2", server2.Addr(), false))).Should(BeNil())
+
+		for i := 0; i < 4; i++ {
+			t := service.GetTicket()
+			_, err := guard.CreateRequest(t, "GET", "/ok")
+			Expect(err).Should(BeNil())
+			Expect(guard.Do(t)).Should(BeNil())
+			t.Rip()
+		}
+
+		Expect(server1.ReceivedRequests()).Should(HaveLen(2))
+		Expect(server2.ReceivedRequests()).Should(HaveLen(2))
+	})
+
+	It("Avoid the failed nodes on retry", func() {
+		nodeFailed := specs.NewRestNode("failed", "127.0.0.1:10000", false)
+		Expect(guard.AddRestNode(service.GetName(), nodeFailed)).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("n1", server1.Addr(), false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("n2", server2.Addr(), false))).Should(BeNil())
+
+		t := service.GetTicket()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(t.Node.Name).Should(Equal("failed"))
+		Expect(guard.Do(t)).Should(BeNil())
+		t.Rip()
+		Expect(t.Retries).Should(Equal(1))
+		Expect(t.Node.Name).ShouldNot(Equal("failed"))
+	})
+
+})
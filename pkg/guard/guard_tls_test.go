@@ -0,0 +1,213 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPem string
+	keyPem  string
+}
+
+func newTestCert(tmpl *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).Should(BeNil())
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	Expect(err).Should(BeNil())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).Should(BeNil())
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	Expect(err).Should(BeNil())
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPem:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})),
+	}
+}
+
+var _ = Describe("TLS Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		node    *specs.RestNode
+		ca      *testCert
+		client  *testCert
+		profile *specs.RestTLS
+	)
+
+	startServer := func(maxVersion uint16) {
+		notAfter := time.Now().Add(time.Hour)
+		ca = newTestCert(&x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "restguard-ca"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              notAfter,
+			IsCA:                  true,
+			KeyUsage:              x509.KeyUsageCertSign,
+			BasicConstraintsValid: true,
+		}, nil)
+		srv := newTestCert(&x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "server.local"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+			DNSNames:     []string{"server.local"},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, ca)
+		client = newTestCert(&x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			Subject:      pkix.Name{CommonName: "restguard-client"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca)
+
+		srvPair, err := tls.X509KeyPair([]byte(srv.certPem), []byte(srv.keyPem))
+		Expect(err).Should(BeNil())
+		pool := x509.NewCertPool()
+		pool.AddCert(ca.cert)
+
+		server = ghttp.NewUnstartedServer()
+		server.HTTPTestServer.TLS = &tls.Config{
+			Certificates: []tls.Certificate{srvPair},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    pool,
+			MaxVersion:   maxVersion,
+		}
+		server.HTTPTestServer.StartTLS()
+		server.RouteToHandler("GET", "/ok", ghttp.RespondWith(http.StatusOK, "OK"))
+
+		profile = &specs.RestTLS{
+			CaPem:   ca.certPem,
+			CertPem: client.certPem,
+			KeyPem:  client.keyPem,
+		}
+	}
+
+	setupGuard := func() {
+		var err error
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		node = specs.NewRestNode("LocalServer", server.Addr(), true)
+		Expect(guard.AddRestNode(service.GetName(), node)).Should(BeNil())
+	}
+
+	doTicket := func(timeoutSec int) error {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		if timeoutSec > 0 {
+			return guard.DoWithTimeout(t, timeoutSec)
+		}
+		return guard.Do(t)
+	}
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("mTLS", func() {
+
+		BeforeEach(func() {
+			startServer(0)
+			setupGuard()
+		})
+
+		It("Use the TLS profile of the node", func() {
+			node.TLS = profile
+			Expect(doTicket(0)).Should(BeNil())
+			Expect(server.ReceivedRequests()).Should(HaveLen(1))
+		})
+
+		It("Use the TLS profile of the service", func() {
+			service.TLS = profile
+			Expect(doTicket(0)).Should(BeNil())
+		})
+
+		It("Fails without the TLS profile", func() {
+			Expect(doTicket(0)).ShouldNot(BeNil())
+			Expect(server.ReceivedRequests()).Should(HaveLen(0))
+		})
+
+		It("DoWithTimeout keeps the TLS profile", func() {
+			node.TLS = profile
+			Expect(doTicket(5)).Should(BeNil())
+		})
+
+		It("Verify the server name of the profile", func() {
+			profile.ServerName = "server.local"
+			node.TLS = profile
+			Expect(doTicket(0)).Should(BeNil())
+
+			other := *profile
+			other.ServerName = "other.local"
+			node.TLS = &other
+			Expect(doTicket(0)).ShouldNot(BeNil())
+		})
+
+	})
+
+	Context("Min version", func() {
+
+		BeforeEach(func() {
+			startServer(tls.VersionTLS12)
+			setupGuard()
+		})
+
+		It("Reject a server under the min version", func() {
+			node.TLS = profile
+			Expect(doTicket(0)).Should(BeNil())
+
+			strict := *profile
+			strict.MinVersion = "1.3"
+			node.TLS = &strict
+			Expect(doTicket(0)).ShouldNot(BeNil())
+		})
+
+		It("Reject an invalid min version", func() {
+			profile.MinVersion = "2.0"
+			_, err := profile.NewConfig()
+			Expect(err).ShouldNot(BeNil())
+		})
+
+	})
+
+})
@@ -0,0 +1,70 @@
+//go:build unix
+
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Umask Download Tests", func() {
+
+	var (
+		server  *ghttp.Server
+		guard   *g.RestGuard
+		service *specs.RestService
+		target  string
+		umask   int
+	)
+
+	BeforeEach(func() {
+		var err error
+		target = filepath.Join(GinkgoT().TempDir(), "artefact.bin")
+
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/file",
+			ghttp.RespondWith(http.StatusOK, "artefact content"))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		service = specs.NewRestService("local-tester")
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+
+		umask = syscall.Umask(0027)
+	})
+
+	AfterEach(func() {
+		syscall.Umask(umask)
+		server.Close()
+	})
+
+	It("Apply the umask to the new artefact", func() {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/file")
+		Expect(err).Should(BeNil())
+
+		_, err = guard.DoDownloadWithOptions(context.Background(), t, target, nil)
+		Expect(err).Should(BeNil())
+
+		info, err := os.Stat(target)
+		Expect(err).Should(BeNil())
+		Expect(info.Mode().Perm()).Should(Equal(os.FileMode(0640)))
+	})
+})
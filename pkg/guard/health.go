@@ -0,0 +1,241 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+// NodeHealth is the status of a node probed by the health checker.
+type NodeHealth struct {
+	Service              string    `json:"service" yaml:"service"`
+	Node                 string    `json:"node" yaml:"node"`
+	Healthy              bool      `json:"healthy" yaml:"healthy"`
+	ConsecutiveFailures  int       `json:"consecutive_failures" yaml:"consecutive_failures"`
+	ConsecutiveSuccesses int       `json:"consecutive_successes" yaml:"consecutive_successes"`
+	LastStatusCode       int       `json:"last_status_code,omitempty" yaml:"last_status_code,omitempty"`
+	LastError            string    `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	LastCheck            time.Time `json:"last_check" yaml:"last_check"`
+}
+
+type HealthChecker struct {
+	guard  *RestGuard
+	mutex  sync.Mutex
+	status map[string]*NodeHealth
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// The services with the health check.
+	services []*specs.RestService
+}
+
+func newHealthChecker(g *RestGuard) *HealthChecker {
+	return &HealthChecker{
+		guard:  g,
+		status: make(map[string]*NodeHealth, 0),
+	}
+}
+
+func healthKey(srv, node string) string { return srv + "/" + node }
+
+// StartHealthChecks starts a goroutine for every service with
+// a health check configured.
+func (g *RestGuard) StartHealthChecks() error {
+	g.healthMutex.Lock()
+	defer g.healthMutex.Unlock()
+
+	if g.healthChecker != nil {
+		return errors.New("Health checks already started")
+	}
+
+	hc := newHealthChecker(g)
+	ctx, cancel := context.WithCancel(context.Background())
+	hc.cancel = cancel
+
+	for _, s := range g.Services {
+		if s.HealthCheck == nil {
+			continue
+		}
+		hc.services = append(hc.services, s)
+		hc.wg.Add(1)
+		go hc.run(ctx, s)
+	}
+
+	g.healthChecker = hc
+	return nil
+}
+
+// StopHealthChecks stops the health checker and waits the end
+// of the running probes. The probed nodes are set as healthy because
+// without probes an unhealthy node is never restored.
+func (g *RestGuard) StopHealthChecks() {
+	g.healthMutex.Lock()
+	hc := g.healthChecker
+	g.healthChecker = nil
+	g.healthMutex.Unlock()
+
+	if hc != nil {
+		hc.cancel()
+		hc.wg.Wait()
+		for _, s := range hc.services {
+			for _, n := range s.GetNodes() {
+				n.SetHealthy(true)
+			}
+		}
+	}
+}
+
+// GetHealthStatus returns a snapshot of the status of the probed nodes.
+func (g *RestGuard) GetHealthStatus() []NodeHealth {
+	g.healthMutex.Lock()
+	hc := g.healthChecker
+	g.healthMutex.Unlock()
+
+	if hc == nil {
+		return []NodeHealth{}
+	}
+	return hc.Snapshot()
+}
+
+func (h *HealthChecker) Snapshot() []NodeHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	ans := make([]NodeHealth, 0, len(h.status))
+	for _, s := range h.status {
+		ans = append(ans, *s)
+	}
+	sort.Slice(ans, func(i, j int) bool {
+		return healthKey(ans[i].Service, ans[i].Node) <
+			healthKey(ans[j].Service, ans[j].Node)
+	})
+	return ans
+}
+
+func (h *HealthChecker) run(ctx context.Context, s *specs.RestService) {
+	defer h.wg.Done()
+
+	ticker := time.NewTicker(s.HealthCheck.GetInterval())
+	defer ticker.Stop()
+
+	for {
+		h.checkService(ctx, s)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *HealthChecker) checkService(ctx context.Context, s *specs.RestService) {
+	var wg sync.WaitGroup
+	for _, n := range s.GetNodes() {
+		if n.Disable {
+			continue
+		}
+		wg.Add(1)
+		go func(n *specs.RestNode) {
+			defer wg.Done()
+			statusCode, err := h.probe(ctx, s, n)
+			if ctx.Err() != nil {
+				// Ignore the probes interrupted by the stop.
+				return
+			}
+			h.update(s, n, statusCode, err)
+		}(n)
+	}
+	wg.Wait()
+}
+
+func (h *HealthChecker) probe(ctx context.Context, s *specs.RestService, n *specs.RestNode) (int, error) {
+	hc := s.HealthCheck
+	ctx, cancel := context.WithTimeout(ctx, hc.GetTimeout())
+	defer cancel()
+
+	url := n.GetUrlPrefix()
+	if strings.HasPrefix(hc.Path, "/") {
+		url += hc.Path
+	} else {
+		url += "/" + hc.Path
+	}
+
+	req, err := http.NewRequestWithContext(h.guard.withTokenClient(ctx, s),
+		hc.GetMethod(), url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if h.guard.GetUserAgent() != "" {
+		req.Header.Add("User-Agent", h.guard.GetUserAgent())
+	}
+	// The probe uses the credentials of the requests of the node.
+	err = authenticate(&specs.RestTicket{Service: s}, n, req)
+	if err != nil {
+		return 0, err
+	}
+
+	client, err := h.guard.nodeClient(h.guard.Client, s, n)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain the body to permit the reuse of the connection.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	valid, err := hc.IsValid(n, resp)
+	if !valid && err == nil {
+		err = fmt.Errorf("received response code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, err
+}
+
+func (h *HealthChecker) update(s *specs.RestService, n *specs.RestNode, statusCode int, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := healthKey(s.GetName(), n.Name)
+	st, ok := h.status[key]
+	if !ok {
+		st = &NodeHealth{
+			Service: s.GetName(),
+			Node:    n.Name,
+		}
+		h.status[key] = st
+	}
+
+	st.LastCheck = time.Now()
+	st.LastStatusCode = statusCode
+	if err != nil {
+		st.LastError = err.Error()
+		st.ConsecutiveFailures++
+		st.ConsecutiveSuccesses = 0
+		if st.ConsecutiveFailures >= s.HealthCheck.GetFailureThreshold() {
+			n.SetHealthy(false)
+		}
+	} else {
+		st.LastError = ""
+		st.ConsecutiveSuccesses++
+		st.ConsecutiveFailures = 0
+		if !n.IsHealthy() &&
+			st.ConsecutiveSuccesses >= s.HealthCheck.GetSuccessThreshold() {
+			n.SetHealthy(true)
+		}
+	}
+	st.Healthy = n.IsHealthy()
+}
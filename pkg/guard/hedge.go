@@ -0,0 +1,364 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+// hedgeRequest is a request of a hedged ticket in flight.
+type hedgeRequest struct {
+	ticket *specs.RestTicket
+	cancel context.CancelFunc
+	result *attemptResult
+}
+
+// release closes the response and the context of the request.
+func (h *hedgeRequest) release() {
+	if h.ticket.Response != nil {
+		h.ticket.Response.Body.Close()
+	}
+	h.cancel()
+}
+
+// cancelOnClose cancels the context of the request when
+// the body of the response is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+func isHedgeable(req *http.Request) bool {
+	if !specs.IsIdempotentMethod(req.Method) &&
+		req.Header.Get(specs.IdempotencyKeyHeader) == "" {
+		return false
+	}
+	// The body must be replayable on the other nodes.
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func (g *RestGuard) DoHedged(t *specs.RestTicket) error {
+	return g.DoHedgedContext(context.Background(), t)
+}
+
+// DoHedgedContext executes the ticket sending the same request to
+// another active node when the node doesn't answer within the hedging
+// delay of the service. The first valid response wins and the other
+// requests are canceled. Every request checks the response like the
+// attempts of DoContext. The ticket contains the node and the closure
+// of the valid response. The services without hedging and the not
+// idempotent requests are executed with DoContext.
+func (g *RestGuard) DoHedgedContext(ctx context.Context, t *specs.RestTicket) error {
+	if t.Request == nil {
+		return errors.New("The ticket is without request.")
+	}
+	if t.Service == nil {
+		return errors.New("The ticket is without service.")
+	}
+
+	if t.Service.IdempotencyKey && t.Request.Header.Get(specs.IdempotencyKeyHeader) == "" {
+		t.Request.Header.Set(specs.IdempotencyKeyHeader, t.Id)
+	}
+
+	if !t.Service.HasHedging() || !isHedgeable(t.Request) {
+		return g.DoContext(ctx, t)
+	}
+
+	lookup, hit := g.lookupCache(t)
+	if hit {
+		return nil
+	}
+	if t.Node == nil {
+		err := bindNode(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	release, err := g.acquireBulkhead(ctx, t)
+	if err != nil {
+		return err
+	}
+	defer holdBulkhead(t, release)
+
+	mws := g.getMiddlewares(t.Service)
+	attempt := specs.ChainAttempt(func(t *specs.RestTicket, req *http.Request) (*http.Response, error) {
+		t.Request = req
+		nc, err := g.nodeClient(g.Client, t.Service, t.Node)
+		if err != nil {
+			return nil, err
+		}
+		reqTime := time.Now()
+		resp, err := nc.Do(req)
+		return lookup.onResponse(t.Service, req, reqTime, resp, err)
+	}, mws...)
+
+	err = specs.ChainTicket(func(ctx context.Context, t *specs.RestTicket) error {
+		return g.doHedged(ctx, attempt, mws, t)
+	}, mws...)(ctx, t)
+	if err == nil {
+		g.updateCache(t, lookup)
+	}
+	return err
+}
+
+// nextHedgeNode returns an active node not yet used by the ticket.
+func nextHedgeNode(t *specs.RestTicket, used specs.RestNodes) *specs.RestNode {
+	activeNodes, _ := getActiveNodes(t.Service)
+	candidates := []*specs.RestNode{}
+	for _, n := range activeNodes {
+		if !used.HasNode(n) {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var ans *specs.RestNode
+	if t.Service.HasNodeSelector() {
+		ans = t.Service.GetNodeSelector().Select(t, candidates)
+	} else {
+		ans = candidates[0]
+	}
+	if b := t.Service.GetBreaker(ans); b != nil {
+		b.Acquire()
+	}
+	return ans
+}
+
+// allowHedge checks the rate limiters without wait. The hedged
+// requests are not sent when the limits are reached.
+func allowHedge(s *specs.RestService, n *specs.RestNode) bool {
+	if n.HasRateLimiter() && n.GetRateLimiter().Tokens() < 1 {
+		return false
+	}
+	if s.HasRateLimiter() && !s.GetRateLimiter().Allow() {
+		return false
+	}
+	return !n.HasRateLimiter() || n.GetRateLimiter().Allow()
+}
+
+// newHedgeRequest creates the request of the ticket for the node.
+func (g *RestGuard) newHedgeRequest(ctx context.Context, t *specs.RestTicket, n *specs.RestNode) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(g.withTokenClient(ctx, t.Service), t.Request.Method,
+		nodeUrl(n, t.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = t.Request.Header.Clone()
+	deauthenticate(t, t.Node, req)
+	err = authenticate(t, n, req)
+	if err != nil {
+		return nil, err
+	}
+	if t.Request.GetBody != nil {
+		body, err := t.Request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+		req.GetBody = t.Request.GetBody
+		req.ContentLength = t.Request.ContentLength
+	}
+	return req, nil
+}
+
+func (g *RestGuard) doHedged(ctx context.Context, attempt specs.AttemptFunc,
+	mws []specs.Middleware, t *specs.RestTicket) error {
+	s := t.Service
+
+	// The max number of requests is the number of nodes plus the retries.
+	results := make(chan *hedgeRequest, len(s.Nodes)+s.Retries+1)
+	inFlight := []*hedgeRequest{}
+	used := specs.RestNodes{}
+	launched := 0
+
+	launch := func(n *specs.RestNode, req *http.Request, hedge bool) {
+		rctx, cancel := context.WithCancel(ctx)
+		h := &hedgeRequest{
+			ticket: &specs.RestTicket{
+				Id:             t.Id,
+				Path:           t.Path,
+				Retries:        t.Retries,
+				Service:        s,
+				Node:           n,
+				FailedNodes:    []*specs.RestNode{},
+				RequestCloseCb: t.RequestCloseCb,
+				// The callbacks of the requests in parallel
+				// use a copy of the closure.
+				Closure: maps.Clone(t.Closure),
+			},
+			cancel: cancel,
+		}
+		launched++
+		inFlight = append(inFlight, h)
+		used = append(used, n)
+
+		go func() {
+			if hedge {
+				defer s.ReleaseHedge()
+			}
+			ht := h.ticket
+			ht.Request = req.WithContext(rctx)
+
+			// Every request refreshes the credentials once.
+			authRefreshed := false
+			res, err := g.doAttempt(rctx, attempt, mws, ht, &authRefreshed)
+			if err != nil {
+				// The errors that stop the attempts are not retried.
+				res = &attemptResult{err: err}
+			}
+			h.result = res
+
+			results <- h
+		}()
+	}
+
+	// releaseInFlight cancels the requests in flight and releases
+	// them when completed.
+	releaseInFlight := func() {
+		for _, h := range inFlight {
+			h.cancel()
+		}
+		go func(n int) {
+			for i := 0; i < n; i++ {
+				(<-results).release()
+			}
+		}(len(inFlight))
+	}
+
+	removeInFlight := func(h *hedgeRequest) {
+		for i := range inFlight {
+			if inFlight[i] == h {
+				inFlight = append(inFlight[:i], inFlight[i+1:]...)
+				return
+			}
+		}
+	}
+
+	err := g.waitRateLimiter(ctx, t, t.Node)
+	if err != nil {
+		return err
+	}
+	launch(t.Node, t.Request, false)
+
+	timer := time.NewTimer(s.GetHedgeDelay())
+	defer timer.Stop()
+
+	var last, winner *hedgeRequest
+	for winner == nil && len(inFlight) > 0 {
+		select {
+		case <-ctx.Done():
+			releaseInFlight()
+			if last != nil {
+				last.release()
+			}
+			return newInterruptedError(t, PhaseRequest, ctx.Err())
+
+		case <-timer.C:
+			if launched < cap(results) && s.AcquireHedge() {
+				var req *http.Request
+				n := nextHedgeNode(t, used)
+				if n != nil && allowHedge(s, n) {
+					req, err = g.newHedgeRequest(ctx, t, n)
+				}
+				if req != nil && err == nil {
+					g.Metrics.ObserveHedge(s.GetName(), n.Name)
+					launch(n, req, true)
+				} else {
+					s.ReleaseHedge()
+				}
+			}
+			timer.Reset(s.GetHedgeDelay())
+
+		case h := <-results:
+			removeInFlight(h)
+			if h.result.err == nil {
+				winner = h
+				break
+			}
+
+			t.AddFail(h.ticket.Node)
+			if last != nil {
+				last.release()
+			}
+			last = h
+
+			if len(inFlight) == 0 && h.result.retry && t.Retries < s.Retries {
+				// All the requests are failed. Retry on another node
+				// or on the same node after the Retry-After interval.
+				n := h.ticket.Node
+				if !h.result.sameNode {
+					n = nextHedgeNode(t, used)
+				}
+				if n == nil {
+					break
+				}
+				failedAttempt := t.Retries + 1
+				t.Retries++
+				g.Metrics.ObserveRetry(s.GetName(), h.ticket.Node.Name)
+				if h.result.retryAfter > 0 {
+					t.LastInterval = h.result.retryAfter
+					err = sleepContext(ctx, h.result.retryAfter)
+					if err != nil {
+						last.release()
+						return newAttemptInterruptedError(t, failedAttempt,
+							h.ticket.Node, PhaseRetryWait, err)
+					}
+				}
+				err = g.waitRateLimiter(ctx, t, n)
+				if err != nil {
+					last.release()
+					return err
+				}
+				req, err := g.newHedgeRequest(ctx, t, n)
+				if err != nil {
+					last.release()
+					return err
+				}
+				launch(n, req, false)
+				timer.Reset(s.GetHedgeDelay())
+			}
+		}
+	}
+
+	if winner == nil {
+		winner = last
+	} else {
+		releaseInFlight()
+		if last != nil {
+			last.release()
+		}
+	}
+
+	t.Node = winner.ticket.Node
+	t.Request = winner.ticket.Request
+	t.Response = winner.ticket.Response
+	t.Closure = winner.ticket.Closure
+	if t.Response != nil {
+		t.Response.Body = &cancelOnClose{
+			ReadCloser: t.Response.Body,
+			cancel:     winner.cancel,
+		}
+	} else {
+		winner.cancel()
+	}
+
+	return winner.result.err
+}
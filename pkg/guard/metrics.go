@@ -0,0 +1,373 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+var downloadBuckets = []float64{
+	.1, .5, 1, 5, 10, 30, 60, 300, 600, 1800,
+}
+
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	values map[string]float64
+	keys   map[string][]string
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64, 0),
+		keys:   make(map[string][]string, 0),
+	}
+}
+
+func (c *counterVec) add(v float64, lvalues ...string) {
+	k := strings.Join(lvalues, "\xff")
+	if _, ok := c.keys[k]; !ok {
+		c.keys[k] = lvalues
+	}
+	c.values[k] += v
+}
+
+func (c *counterVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, k := range sortedKeys(c.keys) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name,
+			formatLabels(c.labels, c.keys[k], "", ""),
+			formatFloat(c.values[k]))
+	}
+}
+
+type gaugeVec struct {
+	*counterVec
+}
+
+func newGaugeVec(name, help string, labels ...string) *gaugeVec {
+	return &gaugeVec{newCounterVec(name, help, labels...)}
+}
+
+func (g *gaugeVec) set(v float64, lvalues ...string) {
+	k := strings.Join(lvalues, "\xff")
+	if _, ok := g.keys[k]; !ok {
+		g.keys[k] = lvalues
+	}
+	g.values[k] = v
+}
+
+func (g *gaugeVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+	for _, k := range sortedKeys(g.keys) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name,
+			formatLabels(g.labels, g.keys[k], "", ""),
+			formatFloat(g.values[k]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	values  map[string]*histogram
+	keys    map[string][]string
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		values:  make(map[string]*histogram, 0),
+		keys:    make(map[string][]string, 0),
+	}
+}
+
+func (h *histogramVec) observe(v float64, lvalues ...string) {
+	k := strings.Join(lvalues, "\xff")
+	hist, ok := h.values[k]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hist
+		h.keys[k] = lvalues
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *histogramVec) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, k := range sortedKeys(h.keys) {
+		hist := h.values[k]
+		lvalues := h.keys[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+				formatLabels(h.labels, lvalues, "le", formatFloat(b)),
+				hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name,
+			formatLabels(h.labels, lvalues, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name,
+			formatLabels(h.labels, lvalues, "", ""), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name,
+			formatLabels(h.labels, lvalues, "", ""), hist.count)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	ans := make([]string, 0, len(m))
+	for k := range m {
+		ans = append(ans, k)
+	}
+	sort.Strings(ans)
+	return ans
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	pairs := []string{}
+	for i, n := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, n, labelEscaper.Replace(values[i])))
+	}
+	if extraName != "" {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extraName, extraValue))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func statusClass(code int) string {
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// Metrics collects the statistics of the services and the nodes
+// and exposes them with the Prometheus text exposition format.
+type Metrics struct {
+	mutex sync.Mutex
+
+	requests         *counterVec
+	transportErrors  *counterVec
+	retries          *counterVec
+	rejections       *counterVec
+	hedges           *counterVec
+	bulkheadRejected *counterVec
+	cacheRequests    *counterVec
+	downloadBytes    *counterVec
+	latency          *histogramVec
+	rateLimiterWait  *histogramVec
+	rateLimit        *gaugeVec
+	downloadDuration *histogramVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: newCounterVec("restguard_requests_total",
+			"Number of HTTP responses received by status class.",
+			"service", "node", "code"),
+		transportErrors: newCounterVec("restguard_transport_errors_total",
+			"Number of requests failed without a response.",
+			"service", "node"),
+		retries: newCounterVec("restguard_retries_total",
+			"Number of retries executed after a failure of the node.",
+			"service", "node"),
+		rejections: newCounterVec("restguard_validator_rejections_total",
+			"Number of responses rejected by the validator.",
+			"service", "node"),
+		hedges: newCounterVec("restguard_hedged_requests_total",
+			"Number of hedged requests sent after the hedging delay.",
+			"service", "node"),
+		bulkheadRejected: newCounterVec("restguard_bulkhead_rejections_total",
+			"Number of tickets rejected by the bulkhead.",
+			"service"),
+		cacheRequests: newCounterVec("restguard_cache_requests_total",
+			"Number of cacheable requests by result: hit, revalidated or miss.",
+			"service", "result"),
+		downloadBytes: newCounterVec("restguard_download_bytes_total",
+			"Number of bytes downloaded.",
+			"service", "node"),
+		latency: newHistogramVec("restguard_request_duration_seconds",
+			"Duration of the attempts until the response headers.",
+			defaultBuckets, "service", "node"),
+		rateLimiterWait: newHistogramVec("restguard_ratelimiter_wait_seconds",
+			"Time waited on the rate limiter.",
+			defaultBuckets, "service"),
+		rateLimit: newGaugeVec("restguard_ratelimiter_rate",
+			"Current rate of the adaptive rate limiter in requests per second.",
+			"service"),
+		downloadDuration: newHistogramVec("restguard_download_duration_seconds",
+			"Duration of the transfer of the downloaded artefacts.",
+			downloadBuckets, "service", "node"),
+	}
+}
+
+func (m *Metrics) ObserveResponse(service, node string, statusCode int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests.add(1, service, node, statusClass(statusCode))
+	m.latency.observe(d.Seconds(), service, node)
+}
+
+func (m *Metrics) ObserveTransportError(service, node string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.transportErrors.add(1, service, node)
+	m.latency.observe(d.Seconds(), service, node)
+}
+
+func (m *Metrics) ObserveRetry(service, node string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.retries.add(1, service, node)
+}
+
+func (m *Metrics) ObserveRejection(service, node string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rejections.add(1, service, node)
+}
+
+func (m *Metrics) ObserveHedge(service, node string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.hedges.add(1, service, node)
+}
+
+func (m *Metrics) ObserveBulkheadRejection(service string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bulkheadRejected.add(1, service)
+}
+
+func (m *Metrics) ObserveCache(service, result string) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.cacheRequests.add(1, service, result)
+}
+
+func (m *Metrics) ObserveRateLimiterWait(service string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rateLimiterWait.observe(d.Seconds(), service)
+}
+
+func (m *Metrics) ObserveRateLimit(service string, r float64) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rateLimit.set(r, service)
+}
+
+func (m *Metrics) ObserveDownload(service, node string, bytes int64, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.downloadBytes.add(float64(bytes), service, node)
+	m.downloadDuration.observe(d.Seconds(), service, node)
+}
+
+// WriteTo writes the metrics with the Prometheus text format.
+func (m *Metrics) WriteTo(out io.Writer) (int64, error) {
+	cw := &countWriter{w: out}
+	w := bufio.NewWriter(cw)
+
+	m.mutex.Lock()
+	m.requests.write(w)
+	m.transportErrors.write(w)
+	m.retries.write(w)
+	m.rejections.write(w)
+	m.hedges.write(w)
+	m.bulkheadRejected.write(w)
+	m.cacheRequests.write(w)
+	m.latency.write(w)
+	m.rateLimiterWait.write(w)
+	m.rateLimit.write(w)
+	m.downloadBytes.write(w)
+	m.downloadDuration.write(w)
+	m.mutex.Unlock()
+
+	err := w.Flush()
+	return cw.n, err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
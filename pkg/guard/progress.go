@@ -0,0 +1,193 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+const (
+	DownloadEventProgress = "progress"
+	DownloadEventRetry    = "retry"
+	DownloadEventDone     = "done"
+
+	DefaultProgressInterval = 500 * time.Millisecond
+)
+
+// DownloadProgress is the status of a download sent to the
+// progress callback.
+type DownloadProgress struct {
+	Event    string `json:"event" yaml:"event"`
+	TicketId string `json:"ticket_id" yaml:"ticket_id"`
+	Path     string `json:"path" yaml:"path"`
+	// The node of the last bytes received or of the failure
+	// on the retry events.
+	Node    string `json:"node,omitempty" yaml:"node,omitempty"`
+	Retries int    `json:"retries,omitempty" yaml:"retries,omitempty"`
+
+	// The bytes written and the size of the artefact. The size
+	// is -1 if unknown.
+	Written int64 `json:"written" yaml:"written"`
+	Total   int64 `json:"total" yaml:"total"`
+
+	// The throughput since the last update and since the
+	// start in bytes per second.
+	Rate    float64 `json:"rate" yaml:"rate"`
+	AvgRate float64 `json:"avg_rate" yaml:"avg_rate"`
+
+	Elapsed time.Duration `json:"elapsed" yaml:"elapsed"`
+	// The estimated time to the end. It's -1 if unknown.
+	Eta time.Duration `json:"eta" yaml:"eta"`
+}
+
+// progressTracker computes the progress of a download and sends
+// the updates to the callback. All the methods accept a nil tracker.
+type progressTracker struct {
+	mutex    sync.Mutex
+	cb       func(p DownloadProgress)
+	interval time.Duration
+	status   DownloadProgress
+
+	start       time.Time
+	lastWritten int64
+	transferred int64
+
+	lastReport            time.Time
+	lastReportTransferred int64
+}
+
+func newProgressTracker(t *specs.RestTicket, artefactPath string, opts *DownloadOptions) *progressTracker {
+	if opts.ProgressCb == nil {
+		return nil
+	}
+	interval := opts.ProgressInterval
+	if interval <= 0 {
+		interval = DefaultProgressInterval
+	}
+	now := time.Now()
+	return &progressTracker{
+		cb:       opts.ProgressCb,
+		interval: interval,
+		status: DownloadProgress{
+			TicketId: t.Id,
+			Path:     artefactPath,
+			Total:    -1,
+			Eta:      -1,
+		},
+		start:      now,
+		lastReport: now,
+	}
+}
+
+// begin sets the bytes already written before the transfer of the
+// body from the node in input. The size is -1 if unknown.
+func (p *progressTracker) begin(node string, written, total int64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.status.Node = node
+	p.status.Written = written
+	p.status.Total = total
+	p.lastWritten = written
+}
+
+// update sets the bytes written and sends the update if the
+// interval is elapsed.
+func (p *progressTracker) update(node string, written int64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if written > p.lastWritten {
+		p.transferred += written - p.lastWritten
+		p.lastWritten = written
+		p.status.Written = written
+	}
+	if node != "" {
+		p.status.Node = node
+	}
+	if time.Since(p.lastReport) >= p.interval {
+		p.report(DownloadEventProgress)
+	}
+}
+
+// retry sends the retry event of the node failed.
+func (p *progressTracker) retry(node string, retries int) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.status.Node = node
+	p.status.Retries = retries
+	p.report(DownloadEventRetry)
+}
+
+// retriesSince sends the retry event if the ticket has executed
+// retries after the retries in input.
+func (p *progressTracker) retriesSince(t *specs.RestTicket, retries int) {
+	if p == nil || t.Retries <= retries || len(t.FailedNodes) == 0 {
+		return
+	}
+	p.retry(t.FailedNodes[len(t.FailedNodes)-1].Name, t.Retries)
+}
+
+func (p *progressTracker) done(a *specs.RestArtefact) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// The progress counts the bytes received.
+	size := a.Size
+	if a.Compressed != nil {
+		size = a.Compressed.Size
+	}
+	p.status.Written = size
+	p.status.Total = size
+	p.report(DownloadEventDone)
+}
+
+func (p *progressTracker) report(event string) {
+	now := time.Now()
+	p.status.Event = event
+	p.status.Elapsed = now.Sub(p.start)
+	if d := now.Sub(p.lastReport).Seconds(); d > 0 {
+		p.status.Rate = float64(p.transferred-p.lastReportTransferred) / d
+	}
+	if d := p.status.Elapsed.Seconds(); d > 0 {
+		p.status.AvgRate = float64(p.transferred) / d
+	}
+	p.status.Eta = -1
+	if p.status.Total >= 0 && p.status.AvgRate > 0 {
+		remaining := float64(p.status.Total - p.status.Written)
+		p.status.Eta = time.Duration(remaining / p.status.AvgRate * float64(time.Second))
+	}
+	p.lastReport = now
+	p.lastReportTransferred = p.transferred
+	p.cb(p.status)
+}
+
+// progressWriterAt counts the bytes written by the segments.
+type progressWriterAt struct {
+	io.WriterAt
+	progress *progressTracker
+	written  *atomic.Int64
+	node     string
+}
+
+func (w *progressWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	n, err := w.WriterAt.WriteAt(b, off)
+	w.progress.update(w.node, w.written.Add(int64(n)))
+	return n, err
+}
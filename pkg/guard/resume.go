@@ -0,0 +1,287 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+const (
+	partSuffix     = ".part"
+	partInfoSuffix = ".part.info"
+)
+
+// partInfo contains the validators of the partial download used
+// with the If-Range header.
+type partInfo struct {
+	Path         string `json:"path"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func (p *partInfo) IfRange() string {
+	// Weak ETags are not permitted with If-Range.
+	if p.ETag != "" && !strings.HasPrefix(p.ETag, "W/") {
+		return p.ETag
+	}
+	return p.LastModified
+}
+
+func loadPartInfo(file string) *partInfo {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	ans := &partInfo{}
+	if err := json.Unmarshal(data, ans); err != nil {
+		return nil
+	}
+	return ans
+}
+
+func (p *partInfo) Write(file string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// parseContentRangeStart returns the first byte of the
+// header Content-Range: bytes <start>-<end>/<size>.
+func parseContentRangeStart(v string) (int64, error) {
+	v = strings.TrimSpace(v)
+	if !strings.HasPrefix(v, "bytes ") {
+		return 0, fmt.Errorf("invalid Content-Range %s", v)
+	}
+	v = strings.TrimPrefix(v, "bytes ")
+	idx := strings.Index(v, "-")
+	if idx <= 0 {
+		return 0, fmt.Errorf("invalid Content-Range %s", v)
+	}
+	return strconv.ParseInt(v[0:idx], 10, 64)
+}
+
+func setRangeHeaders(req *http.Request, offset int64, info *partInfo) {
+	// Avoid the transparent decompression of the body that
+	// breaks the offsets.
+	req.Header.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", info.IfRange())
+	} else {
+		req.Header.Del("Range")
+		req.Header.Del("If-Range")
+	}
+}
+
+// nextDownloadAttempt prepares the ticket to continue the download
+// on the next node after a failure on the body transfer.
+func (g *RestGuard) nextDownloadAttempt(ctx context.Context, t *specs.RestTicket) error {
+	currReq := t.Request
+	prevNode := t.Node
+	t.Retries++
+	t.AddFail(t.Node)
+	t.Node = nil
+	return g.renewRequest(ctx, t, currReq, prevNode)
+}
+
+// nextDownloadNode prepares the ticket to download the artefact
+// from a node not yet tried without using the retries of the service.
+func (g *RestGuard) nextDownloadNode(ctx context.Context, t *specs.RestTicket) error {
+	currReq := t.Request
+	prevNode := t.Node
+	n := untriedNode(t)
+	if n == nil {
+		return errors.New("The service is without nodes to try.")
+	}
+	t.AddFail(t.Node)
+	t.Node = n
+	if b := t.Service.GetBreaker(n); b != nil {
+		b.Acquire()
+	}
+	return g.renewRequest(ctx, t, currReq, prevNode)
+}
+
+func (g *RestGuard) doDownloadResume(ctx context.Context, t *specs.RestTicket,
+	artefactPath string, progress *progressTracker) (*specs.RestArtefact, error) {
+	if t.Request == nil {
+		return nil, errors.New("The ticket is without request.")
+	}
+
+	partPath := artefactPath + partSuffix
+	infoPath := artefactPath + partInfoSuffix
+
+	artefactWriter, err := OpenArtefactWriter(partPath)
+	if err != nil {
+		return nil, err
+	}
+	defer artefactWriter.Close()
+	artefactWriter.progress = progress
+
+	offset, err := artefactWriter.GetSize()
+	if err != nil {
+		return nil, err
+	}
+
+	info := loadPartInfo(infoPath)
+	if offset > 0 && (info == nil || info.Path != t.Path || info.IfRange() == "") {
+		// The partial file is not resumable.
+		offset = 0
+	}
+
+	// Permit only a restart from zero on a not valid range.
+	restarted := false
+	completed := false
+	for {
+		setRangeHeaders(t.Request, offset, info)
+
+		retries := t.Retries
+		err = g.doClient(withRangeDownload(ctx), g.Client, t, false)
+		progress.retriesSince(t, retries)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := t.Response
+		if resp == nil {
+			return nil, fmt.Errorf("invalid response received")
+		}
+
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			start, err := parseContentRangeStart(resp.Header.Get("Content-Range"))
+			if err != nil || start != offset {
+				if restarted {
+					return nil, fmt.Errorf("received invalid range from node %s",
+						t.Node.Name)
+				}
+				resp.Body.Close()
+				offset = 0
+				restarted = true
+				continue
+			}
+			err = artefactWriter.Resume(offset)
+			if err != nil {
+				return nil, fmt.Errorf("error on resume file %s: %s",
+					partPath, err.Error())
+			}
+			total := int64(-1)
+			if resp.ContentLength >= 0 {
+				total = offset + resp.ContentLength
+			}
+			progress.begin(t.Node.Name, offset, total)
+
+		case http.StatusOK:
+			err = artefactWriter.Reset()
+			if err != nil {
+				return nil, fmt.Errorf("error on reset file %s: %s",
+					partPath, err.Error())
+			}
+			progress.begin(t.Node.Name, 0, resp.ContentLength)
+
+		case http.StatusRequestedRangeNotSatisfiable:
+			resp.Body.Close()
+			size, err := parseContentRangeSize(resp.Header.Get("Content-Range"))
+			if err == nil && offset > 0 && size == offset {
+				// The partial file is already complete.
+				err = artefactWriter.Resume(offset)
+				if err != nil {
+					return nil, fmt.Errorf("error on resume file %s: %s",
+						partPath, err.Error())
+				}
+				progress.begin(t.Node.Name, offset, offset)
+				completed = true
+				break
+			}
+			if restarted || offset == 0 {
+				return nil, fmt.Errorf("received response code %d", resp.StatusCode)
+			}
+			offset = 0
+			restarted = true
+			continue
+
+		default:
+			return nil, fmt.Errorf("received response code %d", resp.StatusCode)
+		}
+		if completed {
+			break
+		}
+
+		info = &partInfo{
+			Path:         t.Path,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if info.IfRange() != "" {
+			err = info.Write(infoPath)
+		} else {
+			err = os.Remove(infoPath)
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error on write file %s: %s",
+				infoPath, err.Error())
+		}
+
+		// Read response and write file
+		copyStart := time.Now()
+		n, err := io.Copy(artefactWriter, resp.Body)
+		g.Metrics.ObserveDownload(t.Service.GetName(), t.Node.Name, n,
+			time.Since(copyStart))
+		if err == nil {
+			break
+		}
+
+		if ctx.Err() != nil {
+			return nil, newInterruptedError(t, PhaseDownload, ctx.Err())
+		}
+
+		if t.Retries >= t.Service.Retries || info.IfRange() == "" {
+			return nil, fmt.Errorf("error on writing file %s: %s",
+				partPath, err.Error())
+		}
+
+		// Retry from the bytes received.
+		resp.Body.Close()
+		offset = artefactWriter.GetCount()
+		restarted = false
+		failedNode := t.Node.Name
+		err = g.nextDownloadAttempt(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		progress.retry(failedNode, t.Retries)
+	}
+
+	err = artefactWriter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error on close file %s: %s", partPath, err.Error())
+	}
+
+	// The partial file is renamed by the caller after the checks.
+	ans := &specs.RestArtefact{
+		Path:    partPath,
+		Size:    artefactWriter.GetCount(),
+		Md5:     artefactWriter.MD5(),
+		Sha512:  artefactWriter.Sha512(),
+		Blake2b: artefactWriter.Blake2b(),
+	}
+
+	return ans, nil
+}
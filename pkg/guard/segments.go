@@ -0,0 +1,330 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+)
+
+// DefaultSegmentMinSize is the min size of the segments of
+// a segmented download.
+const DefaultSegmentMinSize int64 = 1024 * 1024
+
+// segment is a range of bytes of the artefact. The end is inclusive.
+type segment struct {
+	index   int
+	start   int64
+	end     int64
+	written int64
+	failed  specs.RestNodes
+}
+
+// nextNode returns the node for the next attempt of the segment. The
+// segments start from different nodes and skip the nodes failed.
+func (s *segment) nextNode(nodes []*specs.RestNode) *specs.RestNode {
+	for i := range nodes {
+		n := nodes[(s.index+i)%len(nodes)]
+		if !s.failed.HasNode(n) {
+			return n
+		}
+	}
+	// All nodes are failed. Restart from the first.
+	s.failed = specs.RestNodes{}
+	return nodes[s.index%len(nodes)]
+}
+
+// nodeValidators are the If-Range validators of the nodes of a
+// segmented download. The validators could be different between the
+// mirrors: the validator of a node is the validator of the first
+// response of the node.
+type nodeValidators struct {
+	values map[string]string
+	mutex  sync.Mutex
+}
+
+func (v *nodeValidators) get(n *specs.RestNode) string {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.values[n.Name]
+}
+
+// setIfMissing sets the validator of the response of the node if
+// the node is without validator.
+func (v *nodeValidators) setIfMissing(n *specs.RestNode, resp *http.Response) {
+	info := &partInfo{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if _, ok := v.values[n.Name]; !ok {
+		v.values[n.Name] = info.IfRange()
+	}
+}
+
+// splitSegments splits the size in max n segments of at
+// least minSize bytes.
+func splitSegments(size int64, n int, minSize int64) []*segment {
+	if minSize <= 0 {
+		minSize = DefaultSegmentMinSize
+	}
+	if limit := (size + minSize - 1) / minSize; int64(n) > limit {
+		n = int(limit)
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	ans := make([]*segment, 0, n)
+	segSize := (size + int64(n) - 1) / int64(n)
+	for start := int64(0); start < size; start += segSize {
+		end := start + segSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		ans = append(ans, &segment{index: len(ans), start: start, end: end})
+	}
+	return ans
+}
+
+// parseContentRangeSize returns the complete length of the header
+// Content-Range: bytes <start>-<end>/<size>. The size is -1 if unknown.
+func parseContentRangeSize(v string) (int64, error) {
+	idx := strings.LastIndex(v, "/")
+	if idx < 0 {
+		return 0, fmt.Errorf("invalid Content-Range %s", v)
+	}
+	if v[idx+1:] == "*" {
+		return -1, nil
+	}
+	return strconv.ParseInt(strings.TrimSpace(v[idx+1:]), 10, 64)
+}
+
+// doDownloadSegmented downloads the segments of the artefact in
+// parallel from the active nodes of the service. The size and the
+// support of the ranges are checked with the request of the first
+// byte. Without ranges the artefact is downloaded with a single request.
+// The download holds a single slot of the bulkhead shared by all
+// the segments.
+func (g *RestGuard) doDownloadSegmented(ctx context.Context, t *specs.RestTicket,
+	file string, opts *DownloadOptions, progress *progressTracker) (*specs.RestArtefact, error) {
+	if t.Request == nil {
+		return nil, errors.New("The ticket is without request.")
+	}
+	if t.Service == nil {
+		return nil, errors.New("The ticket is without service.")
+	}
+
+	release, err := g.acquireBulkhead(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ctx = withBulkheadSlot(ctx)
+
+	t.Request.Header.Set("Accept-Encoding", "identity")
+	t.Request.Header.Set("Range", "bytes=0-0")
+	t.Request.Header.Del("If-Range")
+	retries := t.Retries
+	err = g.doClient(withRangeDownload(ctx), g.Client, t, false)
+	progress.retriesSince(t, retries)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := t.Response
+	size := int64(-1)
+	if resp.StatusCode == http.StatusPartialContent {
+		size, err = parseContentRangeSize(resp.Header.Get("Content-Range"))
+		if err != nil {
+			size = -1
+		}
+	}
+	resp.Body.Close()
+	t.Request.Header.Del("Range")
+	if size < 0 {
+		// The node doesn't support the ranges.
+		return g.doDownload(ctx, t, file, "", progress)
+	}
+
+	validators := &nodeValidators{values: map[string]string{}}
+	validators.setIfMissing(t.Node, resp)
+
+	nodes, _ := getActiveNodes(t.Service)
+	if len(nodes) == 0 {
+		nodes = []*specs.RestNode{t.Node}
+	}
+
+	fd, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("error on create file %s: %s", file, err.Error())
+	}
+	err = fd.Truncate(size)
+	if err != nil {
+		fd.Close()
+		os.Remove(file)
+		return nil, fmt.Errorf("error on truncate file %s: %s", file, err.Error())
+	}
+
+	segments := splitSegments(size, opts.Segments, opts.SegmentMinSize)
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress.begin(t.Node.Name, 0, size)
+	written := &atomic.Int64{}
+
+	errs := make(chan error, len(segments))
+	for _, seg := range segments {
+		go func(seg *segment) {
+			w := &progressWriterAt{WriterAt: fd, progress: progress, written: written}
+			errs <- g.downloadSegment(sctx, t, w, seg, size, nodes, validators, progress)
+		}(seg)
+	}
+
+	var ans error
+	for range segments {
+		if err := <-errs; err != nil && ans == nil {
+			// Stop the other segments.
+			ans = err
+			cancel()
+		}
+	}
+
+	if err := fd.Close(); err != nil && ans == nil {
+		ans = fmt.Errorf("error on close file %s: %s", file, err.Error())
+	}
+	if ans != nil {
+		os.Remove(file)
+		if ctx.Err() != nil {
+			return nil, newInterruptedError(t, PhaseDownload, ctx.Err())
+		}
+		return nil, ans
+	}
+
+	// The hashes are computed on the whole file in order.
+	artefactWriter, err := OpenArtefactWriter(file)
+	if err != nil {
+		return nil, err
+	}
+	defer artefactWriter.Close()
+	err = artefactWriter.Resume(size)
+	if err != nil {
+		return nil, fmt.Errorf("error on read file %s: %s", file, err.Error())
+	}
+
+	return &specs.RestArtefact{
+		Path:    file,
+		Size:    artefactWriter.GetCount(),
+		Md5:     artefactWriter.MD5(),
+		Sha512:  artefactWriter.Sha512(),
+		Blake2b: artefactWriter.Blake2b(),
+	}, nil
+}
+
+// downloadSegment writes the segment in the file. On failure the
+// download continues on another node from the bytes received. The
+// responses of every node are checked with the validator of the node
+// and with the size of the artefact.
+func (g *RestGuard) downloadSegment(ctx context.Context, t *specs.RestTicket,
+	w *progressWriterAt, seg *segment, size int64, nodes []*specs.RestNode,
+	validators *nodeValidators, progress *progressTracker) error {
+
+	var lastErr error
+	maxAttempts := len(nodes) + t.Service.Retries
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		st := &specs.RestTicket{
+			Id:          t.Id,
+			Service:     t.Service,
+			Node:        seg.nextNode(nodes),
+			FailedNodes: []*specs.RestNode{},
+			Closure:     maps.Clone(t.Closure),
+		}
+		req, err := g.createRequest(ctx, st, http.MethodGet, t.Path, false)
+		if err != nil {
+			return err
+		}
+		req.Header = t.Request.Header.Clone()
+		// The headers contain the credentials of the node of the probe.
+		deauthenticate(t, t.Node, req)
+		err = authenticate(st, st.Node, req)
+		if err != nil {
+			return err
+		}
+
+		offset := seg.start + seg.written
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, seg.end))
+		ifRange := validators.get(st.Node)
+		if ifRange != "" {
+			req.Header.Set("If-Range", ifRange)
+		} else {
+			req.Header.Del("If-Range")
+		}
+
+		err = g.doClient(withRangeDownload(ctx), g.Client, st, false)
+		if err != nil {
+			if ctx.Err() != nil {
+				return newInterruptedError(st, PhaseDownload, ctx.Err())
+			}
+			lastErr = err
+			seg.failed = append(seg.failed, st.Node)
+			progress.retry(st.Node.Name, attempt+1)
+			continue
+		}
+
+		resp := st.Response
+		total, err := parseContentRangeSize(resp.Header.Get("Content-Range"))
+		if (resp.StatusCode == http.StatusOK && ifRange != "") ||
+			(resp.StatusCode == http.StatusPartialContent && err == nil && total != size) {
+			// The artefact is changed on the node.
+			resp.Body.Close()
+			lastErr = fmt.Errorf("the artefact is changed on node %s", st.Node.Name)
+			seg.failed = append(seg.failed, st.Node)
+			progress.retry(st.Node.Name, attempt+1)
+			continue
+		}
+		start, err := parseContentRangeStart(resp.Header.Get("Content-Range"))
+		if resp.StatusCode != http.StatusPartialContent || err != nil || start != offset {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("received invalid range from node %s", st.Node.Name)
+			seg.failed = append(seg.failed, st.Node)
+			progress.retry(st.Node.Name, attempt+1)
+			continue
+		}
+
+		validators.setIfMissing(st.Node, resp)
+
+		copyStart := time.Now()
+		w.node = st.Node.Name
+		n, err := io.CopyN(io.NewOffsetWriter(w, offset), resp.Body, seg.end+1-offset)
+		resp.Body.Close()
+		seg.written += n
+		g.Metrics.ObserveDownload(t.Service.GetName(), st.Node.Name, n, time.Since(copyStart))
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return newInterruptedError(st, PhaseDownload, ctx.Err())
+		}
+		lastErr = fmt.Errorf("error on download segment from node %s: %s",
+			st.Node.Name, err.Error())
+		seg.failed = append(seg.failed, st.Node)
+		progress.retry(st.Node.Name, attempt+1)
+	}
+
+	return lastErr
+}
@@ -0,0 +1,171 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	RateLimiterStatic   = "static"
+	RateLimiterAdaptive = "adaptive"
+
+	DefaultAdaptiveIncrease = 1.0
+	DefaultAdaptiveDecrease = 0.5
+	// The default max rate is the initial rate multiplied by this
+	// factor.
+	DefaultAdaptiveMaxFactor = 10.0
+
+	// Min interval between two decreases of the rate. The failures
+	// of the requests in flight are managed as a single event.
+	adaptiveDecreaseInterval = time.Second
+)
+
+// AdaptiveLimiter changes the rate of the limiter with the AIMD
+// algorithm: the rate is increased of increase reqs/s every rate
+// successes and it's multiplied by decrease on throttling.
+type AdaptiveLimiter struct {
+	Limiter *rate.Limiter
+
+	min, max     float64
+	increase     float64
+	decrease     float64
+	rate         float64
+	lastDecrease time.Time
+	mutex        sync.Mutex
+}
+
+func NewAdaptiveLimiter(initial, min, max, increase, decrease float64) (*AdaptiveLimiter, error) {
+	if min <= 0 || max < min {
+		return nil, fmt.Errorf("Invalid adaptive rate bounds %v-%v", min, max)
+	}
+	if increase <= 0 {
+		return nil, fmt.Errorf("Invalid adaptive rate increase %v", increase)
+	}
+	if decrease <= 0 || decrease >= 1 {
+		return nil, fmt.Errorf("Invalid adaptive rate decrease %v", decrease)
+	}
+	initial = math.Min(math.Max(initial, min), max)
+
+	return &AdaptiveLimiter{
+		Limiter:  rate.NewLimiter(rate.Limit(initial), burstOf(initial)),
+		min:      min,
+		max:      max,
+		increase: increase,
+		decrease: decrease,
+		rate:     initial,
+	}, nil
+}
+
+func burstOf(r float64) int {
+	return int(math.Max(1, math.Ceil(r)))
+}
+
+func (a *AdaptiveLimiter) setRate(r float64) {
+	a.rate = math.Min(math.Max(r, a.min), a.max)
+	a.Limiter.SetLimit(rate.Limit(a.rate))
+	a.Limiter.SetBurst(burstOf(a.rate))
+}
+
+// GetRate returns the current rate in reqs/s.
+func (a *AdaptiveLimiter) GetRate() float64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.rate
+}
+
+func (a *AdaptiveLimiter) OnSuccess() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.rate < a.max {
+		a.setRate(a.rate + a.increase/a.rate)
+	}
+}
+
+// OnThrottle decreases the rate after a 429, 503 or a timeout.
+func (a *AdaptiveLimiter) OnThrottle() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if time.Since(a.lastDecrease) < adaptiveDecreaseInterval {
+		return
+	}
+	a.lastDecrease = time.Now()
+	a.setRate(a.rate * a.decrease)
+}
+
+// OnResponse updates the rate with the status code of a response.
+// The server errors different from 503 don't change the rate.
+func (a *AdaptiveLimiter) OnResponse(statusCode int) {
+	switch {
+	case statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusServiceUnavailable:
+		a.OnThrottle()
+	case statusCode < 500:
+		a.OnSuccess()
+	}
+}
+
+func (s *RestService) HasAdaptiveLimiter() bool             { return s.AdaptiveLimiter != nil }
+func (s *RestService) GetAdaptiveLimiter() *AdaptiveLimiter { return s.AdaptiveLimiter }
+
+// GetRateLimit returns the current rate of the rate limiter in reqs/s.
+func (s *RestService) GetRateLimit() float64 {
+	if s.AdaptiveLimiter != nil {
+		return s.AdaptiveLimiter.GetRate()
+	}
+	if s.RateLimiter != nil {
+		return float64(s.RateLimiter.Limit())
+	}
+	return 0
+}
+
+func (s *RestService) getFloatOption(k string, def float64) (float64, error) {
+	v, err := s.GetOption(k)
+	if err != nil {
+		return def, nil
+	}
+	ans, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s option: %s", k, err.Error())
+	}
+	return ans, nil
+}
+
+// setAdaptiveLimiter creates the adaptive limiter with the initial
+// rate in input and the rate_limiter_* options. Without the max option
+// the rate grows up to DefaultAdaptiveMaxFactor times the initial rate.
+func (s *RestService) setAdaptiveLimiter(initial float64) error {
+	min, err := s.getFloatOption(ServiceRateLimiterMin, 1)
+	if err != nil {
+		return err
+	}
+	max, err := s.getFloatOption(ServiceRateLimiterMax, initial*DefaultAdaptiveMaxFactor)
+	if err != nil {
+		return err
+	}
+	increase, err := s.getFloatOption(ServiceRateLimiterIncrease, DefaultAdaptiveIncrease)
+	if err != nil {
+		return err
+	}
+	decrease, err := s.getFloatOption(ServiceRateLimiterDecrease, DefaultAdaptiveDecrease)
+	if err != nil {
+		return err
+	}
+
+	a, err := NewAdaptiveLimiter(initial, min, max, increase, decrease)
+	if err != nil {
+		return err
+	}
+	s.AdaptiveLimiter = a
+	s.RateLimiter = a.Limiter
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs_test
+
+import (
+	"net/http"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+)
+
+var _ = Describe("Adaptive Rate Limiter Test", func() {
+
+	It("Increase additively and decrease multiplicatively", func() {
+		a, err := specs.NewAdaptiveLimiter(10, 2, 12, 5, 0.5)
+		Expect(err).Should(BeNil())
+		Expect(a.GetRate()).Should(Equal(10.0))
+
+		a.OnResponse(http.StatusOK)
+		Expect(a.GetRate()).Should(Equal(10.5))
+		for i := 0; i < 100; i++ {
+			a.OnSuccess()
+		}
+		Expect(a.GetRate()).Should(Equal(12.0))
+		Expect(a.Limiter.Limit()).Should(Equal(rate.Limit(12)))
+
+		a.OnResponse(http.StatusTooManyRequests)
+		Expect(a.GetRate()).Should(Equal(6.0))
+		Expect(a.Limiter.Burst()).Should(Equal(6))
+
+		// The failures of the requests in flight are a single event.
+		a.OnResponse(http.StatusServiceUnavailable)
+		Expect(a.GetRate()).Should(Equal(6.0))
+
+		// The server errors don't change the rate.
+		a.OnResponse(http.StatusInternalServerError)
+		Expect(a.GetRate()).Should(Equal(6.0))
+	})
+
+	It("Setup from the service options", func() {
+		s := specs.NewRestService("test")
+		s.SetOption(specs.ServiceRateLimiter, "20")
+		s.SetOption(specs.ServiceRateLimiterMode, specs.RateLimiterAdaptive)
+		s.SetOption(specs.ServiceRateLimiterMin, "5")
+		s.SetOption(specs.ServiceRateLimiterMax, "50")
+		Expect(s.SetRateLimiter()).Should(BeNil())
+		Expect(s.HasAdaptiveLimiter()).Should(BeTrue())
+		Expect(s.GetRateLimit()).Should(Equal(20.0))
+		Expect(s.GetRateLimiter()).Should(Equal(s.GetAdaptiveLimiter().Limiter))
+
+		c := s.Clone()
+		Expect(c.HasAdaptiveLimiter()).Should(BeTrue())
+		Expect(c.GetAdaptiveLimiter()).ShouldNot(BeIdenticalTo(s.GetAdaptiveLimiter()))
+
+		// The static mode reads the option with the same rate.
+		s.SetOption(specs.ServiceRateLimiterMode, specs.RateLimiterStatic)
+		Expect(s.SetRateLimiter()).Should(BeNil())
+		Expect(s.HasAdaptiveLimiter()).Should(BeFalse())
+		Expect(s.GetRateLimit()).Should(Equal(20.0))
+	})
+
+	It("Increase the rate without the max option", func() {
+		s := specs.NewRestService("test")
+		s.SetOption(specs.ServiceRateLimiter, "2")
+		s.SetOption(specs.ServiceRateLimiterMode, specs.RateLimiterAdaptive)
+		Expect(s.SetRateLimiter()).Should(BeNil())
+		Expect(s.GetRateLimit()).Should(Equal(2.0))
+
+		a := s.GetAdaptiveLimiter()
+		for i := 0; i < 1000; i++ {
+			a.OnResponse(http.StatusOK)
+		}
+		Expect(s.GetRateLimit()).Should(BeNumerically(">", 2.0))
+		Expect(s.GetRateLimit()).Should(BeNumerically("<=", 2.0*specs.DefaultAdaptiveMaxFactor))
+	})
+
+	It("Invalid options", func() {
+		s := specs.NewRestService("test")
+		s.SetOption(specs.ServiceRateLimiter, "20")
+		s.SetOption(specs.ServiceRateLimiterMode, "foo")
+		Expect(s.SetRateLimiter()).ShouldNot(BeNil())
+
+		s.SetOption(specs.ServiceRateLimiterMode, specs.RateLimiterAdaptive)
+		s.SetOption(specs.ServiceRateLimiterDecrease, "2")
+		Expect(s.SetRateLimiter()).ShouldNot(BeNil())
+	})
+
+})
@@ -0,0 +1,360 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthApiKey = "apikey"
+	AuthOAuth2 = "oauth2"
+
+	ApiKeyInHeader = "header"
+	ApiKeyInQuery  = "query"
+
+	// Interval before the expiry of the token when a new token
+	// is requested.
+	DefaultOAuth2RefreshBefore = 30 * time.Second
+)
+
+// Authenticator sets the credentials of the requests.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// RefreshableAuthenticator is an Authenticator with credentials that
+// could be refreshed after a 401 response.
+type RefreshableAuthenticator interface {
+	Authenticator
+	// Invalidate forces new credentials at the next Authenticate.
+	Invalidate()
+}
+
+// Deauthenticator is an Authenticator that removes its credentials
+// from a request before the request is sent to another node.
+type Deauthenticator interface {
+	Deauthenticate(req *http.Request)
+}
+
+// RestAuth describes an Authenticator in the configuration file.
+type RestAuth struct {
+	Type string `json:"type" yaml:"type" mapstructure:"type"`
+
+	// Basic authentication.
+	Username string `json:"username,omitempty" yaml:"username,omitempty" mapstructure:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty" mapstructure:"password,omitempty"`
+
+	// Bearer token.
+	Token string `json:"token,omitempty" yaml:"token,omitempty" mapstructure:"token,omitempty"`
+
+	// API key in the header or query parameter Name.
+	Name  string `json:"name,omitempty" yaml:"name,omitempty" mapstructure:"name,omitempty"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty" mapstructure:"value,omitempty"`
+	In    string `json:"in,omitempty" yaml:"in,omitempty" mapstructure:"in,omitempty"`
+
+	// OAuth2 client credentials.
+	TokenUrl     string   `json:"token_url,omitempty" yaml:"token_url,omitempty" mapstructure:"token_url,omitempty"`
+	ClientId     string   `json:"client_id,omitempty" yaml:"client_id,omitempty" mapstructure:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty" yaml:"client_secret,omitempty" mapstructure:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty" yaml:"scopes,omitempty" mapstructure:"scopes,omitempty"`
+}
+
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a *BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+func (a *BasicAuth) Deauthenticate(req *http.Request) {
+	req.Header.Del("Authorization")
+}
+
+type BearerAuth struct {
+	Token string
+}
+
+func (a *BearerAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+func (a *BearerAuth) Deauthenticate(req *http.Request) {
+	req.Header.Del("Authorization")
+}
+
+// ApiKeyAuth sets the key as header or as query parameter.
+type ApiKeyAuth struct {
+	Name  string
+	Value string
+	In    string
+}
+
+func (a *ApiKeyAuth) Authenticate(req *http.Request) error {
+	if a.In == ApiKeyInQuery {
+		q := req.URL.Query()
+		q.Set(a.Name, a.Value)
+		req.URL.RawQuery = q.Encode()
+	} else {
+		req.Header.Set(a.Name, a.Value)
+	}
+	return nil
+}
+
+func (a *ApiKeyAuth) Deauthenticate(req *http.Request) {
+	if a.In == ApiKeyInQuery {
+		q := req.URL.Query()
+		if q.Has(a.Name) {
+			q.Del(a.Name)
+			req.URL.RawQuery = q.Encode()
+		}
+	} else {
+		req.Header.Del(a.Name)
+	}
+}
+
+// tokenClientKey is the key of the context of the requests with
+// the client used for the token requests.
+type tokenClientKey struct{}
+
+// WithTokenClient returns a context with the client used by the
+// authenticators without a client for the token requests.
+func WithTokenClient(ctx context.Context, c *http.Client) context.Context {
+	return context.WithValue(ctx, tokenClientKey{}, c)
+}
+
+func tokenClient(ctx context.Context) *http.Client {
+	if c, ok := ctx.Value(tokenClientKey{}).(*http.Client); ok && c != nil {
+		return c
+	}
+	return http.DefaultClient
+}
+
+// OAuth2ClientCredentials requests the tokens with the client
+// credentials grant. The token is cached and it's requested again
+// before the expiry. The concurrent requests wait the same token
+// request.
+type OAuth2ClientCredentials struct {
+	TokenUrl     string
+	ClientId     string
+	ClientSecret string
+	Scopes       []string
+	// The client used for the token requests. Default is the client
+	// of the context of the request or http.DefaultClient.
+	Client        *http.Client
+	RefreshBefore time.Duration
+
+	token  string
+	expiry time.Time
+	fetch  *tokenFetch
+	mutex  sync.Mutex
+}
+
+// tokenFetch is a token request in progress.
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func NewOAuth2ClientCredentials(tokenUrl, clientId, clientSecret string, scopes ...string) *OAuth2ClientCredentials {
+	return &OAuth2ClientCredentials{
+		TokenUrl:      tokenUrl,
+		ClientId:      clientId,
+		ClientSecret:  clientSecret,
+		Scopes:        scopes,
+		RefreshBefore: DefaultOAuth2RefreshBefore,
+	}
+}
+
+func (a *OAuth2ClientCredentials) Invalidate() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.token = ""
+}
+
+func (a *OAuth2ClientCredentials) Deauthenticate(req *http.Request) {
+	req.Header.Del("Authorization")
+}
+
+func (a *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+	a.mutex.Lock()
+	if a.token != "" &&
+		(a.expiry.IsZero() || !time.Now().Add(a.RefreshBefore).After(a.expiry)) {
+		token := a.token
+		a.mutex.Unlock()
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+
+	f := a.fetch
+	if f == nil {
+		// The token is requested without the lock.
+		f = &tokenFetch{done: make(chan struct{})}
+		a.fetch = f
+		client := a.Client
+		a.mutex.Unlock()
+
+		if client == nil {
+			client = tokenClient(req.Context())
+		}
+		token, expiry, err := a.fetchToken(req.Context(), client)
+
+		a.mutex.Lock()
+		if err == nil {
+			a.token = token
+			a.expiry = expiry
+		}
+		a.fetch = nil
+		a.mutex.Unlock()
+
+		f.token, f.err = token, err
+		close(f.done)
+	} else {
+		a.mutex.Unlock()
+		select {
+		case <-f.done:
+		case <-req.Context().Done():
+			return fmt.Errorf("error on token request: %s", req.Context().Err().Error())
+		}
+	}
+
+	if f.err != nil {
+		return f.err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.token)
+	return nil
+}
+
+func (a *OAuth2ClientCredentials) fetchToken(ctx context.Context, client *http.Client) (string, time.Time, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(a.Scopes) > 0 {
+		form.Set("scope", strings.Join(a.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", a.TokenUrl,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error on create token request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(a.ClientId), url.QueryEscape(a.ClientSecret))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error on token request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error on read token response: %s", err.Error())
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	tr := struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(data, &tr); err != nil {
+		return "", time.Time{}, fmt.Errorf("error on parse token response: %s", err.Error())
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token response without access_token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", time.Time{}, fmt.Errorf("unsupported token type %s", tr.TokenType)
+	}
+
+	var expiry time.Time
+	if tr.ExpiresIn > 0 {
+		expiry = time.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return tr.AccessToken, expiry, nil
+}
+
+// NewAuthenticator creates the Authenticator described by the
+// configuration in input.
+func NewAuthenticator(a *RestAuth) (Authenticator, error) {
+	switch a.Type {
+	case AuthBasic:
+		return &BasicAuth{Username: a.Username, Password: a.Password}, nil
+	case AuthBearer:
+		if a.Token == "" {
+			return nil, fmt.Errorf("bearer auth without token")
+		}
+		return &BearerAuth{Token: a.Token}, nil
+	case AuthApiKey:
+		if a.Name == "" {
+			return nil, fmt.Errorf("apikey auth without name")
+		}
+		switch a.In {
+		case "", ApiKeyInHeader, ApiKeyInQuery:
+		default:
+			return nil, fmt.Errorf("invalid apikey location %s", a.In)
+		}
+		return &ApiKeyAuth{Name: a.Name, Value: a.Value, In: a.In}, nil
+	case AuthOAuth2:
+		if a.TokenUrl == "" {
+			return nil, fmt.Errorf("oauth2 auth without token_url")
+		}
+		return NewOAuth2ClientCredentials(a.TokenUrl, a.ClientId,
+			a.ClientSecret, a.Scopes...), nil
+	}
+	return nil, fmt.Errorf("invalid auth type %s", a.Type)
+}
+
+// SetAuthenticator creates the Authenticator of the service from Auth.
+func (s *RestService) SetAuthenticator() error {
+	if s.Auth == nil {
+		return nil
+	}
+	a, err := NewAuthenticator(s.Auth)
+	if err != nil {
+		return err
+	}
+	s.Authenticator = a
+	return nil
+}
+
+// SetAuthenticator creates the Authenticator of the node from Auth.
+func (n *RestNode) SetAuthenticator() error {
+	if n.Auth == nil {
+		return nil
+	}
+	a, err := NewAuthenticator(n.Auth)
+	if err != nil {
+		return err
+	}
+	n.Authenticator = a
+	return nil
+}
+
+// GetAuthenticator returns the Authenticator of the node or
+// of the service.
+func (s *RestService) GetAuthenticator(n *RestNode) Authenticator {
+	if n != nil && n.Authenticator != nil {
+		return n.Authenticator
+	}
+	return s.Authenticator
+}
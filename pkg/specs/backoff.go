@@ -0,0 +1,147 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	BackoffConstant           = "constant"
+	BackoffLinear             = "linear"
+	BackoffExponential        = "exponential"
+	BackoffFullJitter         = "full-jitter"
+	BackoffDecorrelatedJitter = "decorrelated-jitter"
+)
+
+// BackoffPolicy defines the interval to wait between the retries
+// of a ticket.
+type BackoffPolicy interface {
+	// Next returns the interval to wait before the retry in input
+	// (starting from 1). prev is the interval used by the previous
+	// retry or 0.
+	Next(retry int, prev time.Duration) time.Duration
+}
+
+func capInterval(d, max time.Duration) time.Duration {
+	if d < 0 {
+		d = 0
+	}
+	if max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// expInterval returns base * mult^(retry-1) without overflow.
+func expInterval(base time.Duration, mult float64, retry int) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+	if mult <= 1 {
+		mult = 2
+	}
+	v := float64(base) * math.Pow(mult, float64(retry-1))
+	if v >= float64(math.MaxInt64) || math.IsInf(v, 0) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(v)
+}
+
+func randBetween(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)))
+}
+
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+func (b *ConstantBackoff) Next(retry int, prev time.Duration) time.Duration {
+	return b.Interval
+}
+
+type LinearBackoff struct {
+	Initial time.Duration
+	Step    time.Duration
+	Max     time.Duration
+}
+
+func (b *LinearBackoff) Next(retry int, prev time.Duration) time.Duration {
+	if retry < 1 {
+		retry = 1
+	}
+	return capInterval(b.Initial+time.Duration(retry-1)*b.Step, b.Max)
+}
+
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Multiplier float64
+	Max        time.Duration
+}
+
+func (b *ExponentialBackoff) Next(retry int, prev time.Duration) time.Duration {
+	return capInterval(expInterval(b.Initial, b.Multiplier, retry), b.Max)
+}
+
+// FullJitterBackoff waits a random interval between 0 and the
+// exponential interval of the retry.
+type FullJitterBackoff struct {
+	Initial    time.Duration
+	Multiplier float64
+	Max        time.Duration
+}
+
+func (b *FullJitterBackoff) Next(retry int, prev time.Duration) time.Duration {
+	return randBetween(0, capInterval(expInterval(b.Initial, b.Multiplier, retry), b.Max))
+}
+
+// DecorrelatedJitterBackoff waits a random interval between the
+// initial interval and three times the previous interval.
+type DecorrelatedJitterBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+func (b *DecorrelatedJitterBackoff) Next(retry int, prev time.Duration) time.Duration {
+	if prev < b.Initial {
+		prev = b.Initial
+	}
+	upper := prev * 3
+	if upper < prev {
+		// Overflow
+		upper = time.Duration(math.MaxInt64)
+	}
+	return capInterval(randBetween(b.Initial, upper), b.Max)
+}
+
+// NewBackoffPolicy creates the policy with the name in input.
+// A zero multiplier means the default value 2.
+// For the linear policy the step is equal to the initial interval.
+func NewBackoffPolicy(name string, initial, max time.Duration, mult float64) (BackoffPolicy, error) {
+	if mult != 0 && (mult <= 1 || math.IsNaN(mult)) {
+		return nil, fmt.Errorf("Invalid backoff multiplier %v: it must be greater than 1", mult)
+	}
+
+	switch name {
+	case BackoffConstant:
+		return &ConstantBackoff{Interval: capInterval(initial, max)}, nil
+	case BackoffLinear:
+		return &LinearBackoff{Initial: initial, Step: initial, Max: max}, nil
+	case BackoffExponential:
+		return &ExponentialBackoff{Initial: initial, Multiplier: mult, Max: max}, nil
+	case BackoffFullJitter:
+		return &FullJitterBackoff{Initial: initial, Multiplier: mult, Max: max}, nil
+	case BackoffDecorrelatedJitter:
+		return &DecorrelatedJitterBackoff{Initial: initial, Max: max}, nil
+	default:
+		return nil, fmt.Errorf("Invalid backoff policy %s", name)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs_test
+
+import (
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff Policies Test", func() {
+
+	Context("Deterministic policies", func() {
+
+		It("Constant", func() {
+			p, err := specs.NewBackoffPolicy(specs.BackoffConstant,
+				10*time.Millisecond, 0, 0)
+			Expect(err).Should(BeNil())
+			Expect(p.Next(1, 0)).Should(Equal(10 * time.Millisecond))
+			Expect(p.Next(5, 0)).Should(Equal(10 * time.Millisecond))
+		})
+
+		It("Linear with cap", func() {
+			p, err := specs.NewBackoffPolicy(specs.BackoffLinear,
+				10*time.Millisecond, 25*time.Millisecond, 0)
+			Expect(err).Should(BeNil())
+			Expect(p.Next(1, 0)).Should(Equal(10 * time.Millisecond))
+			Expect(p.Next(2, 0)).Should(Equal(20 * time.Millisecond))
+			Expect(p.Next(3, 0)).Should(Equal(25 * time.Millisecond))
+		})
+
+		It("Exponential with cap", func() {
+			p, err := specs.NewBackoffPolicy(specs.BackoffExponential,
+				10*time.Millisecond, time.Second, 3)
+			Expect(err).Should(BeNil())
+			Expect(p.Next(1, 0)).Should(Equal(10 * time.Millisecond))
+			Expect(p.Next(2, 0)).Should(Equal(30 * time.Millisecond))
+			Expect(p.Next(3, 0)).Should(Equal(90 * time.Millisecond))
+			Expect(p.Next(100, 0)).Should(Equal(time.Second))
+		})
+
+		It("Invalid name", func() {
+			p, err := specs.NewBackoffPolicy("foo", time.Millisecond, 0, 0)
+			Expect(err).ShouldNot(BeNil())
+			Expect(p).Should(BeNil())
+		})
+	})
+
+	Context("Jitter policies", func() {
+
+		It("Full jitter", func() {
+			p, err := specs.NewBackoffPolicy(specs.BackoffFullJitter,
+				10*time.Millisecond, 50*time.Millisecond, 0)
+			Expect(err).Should(BeNil())
+			for i := 1; i < 20; i++ {
+				d := p.Next(i, 0)
+				Expect(d).Should(BeNumerically(">=", 0))
+				Expect(d).Should(BeNumerically("<=", 50*time.Millisecond))
+			}
+		})
+
+		It("Decorrelated jitter", func() {
+			p, err := specs.NewBackoffPolicy(specs.BackoffDecorrelatedJitter,
+				10*time.Millisecond, 100*time.Millisecond, 0)
+			Expect(err).Should(BeNil())
+			prev := time.Duration(0)
+			for i := 1; i < 20; i++ {
+				d := p.Next(i, prev)
+				Expect(d).Should(BeNumerically(">=", 10*time.Millisecond))
+				Expect(d).Should(BeNumerically("<=", 100*time.Millisecond))
+				prev = d
+			}
+		})
+	})
+
+	Context("Service options", func() {
+
+		It("Create policy from options", func() {
+			s := specs.NewRestService("service1")
+			s.RetryIntervalMs = 100
+			s.SetOption(specs.ServiceBackoff, specs.BackoffExponential)
+			s.SetOption(specs.ServiceBackoffMaxIntervalMs, "300")
+			Expect(s.SetBackoff()).Should(BeNil())
+			Expect(s.HasBackoffPolicy()).Should(BeTrue())
+			Expect(s.GetBackoffPolicy().Next(2, 0)).Should(Equal(200 * time.Millisecond))
+			Expect(s.GetBackoffPolicy().Next(3, 0)).Should(Equal(300 * time.Millisecond))
+			Expect(s.Clone().HasBackoffPolicy()).Should(BeTrue())
+		})
+
+		It("Create policy from fields", func() {
+			s := specs.NewRestService("service1")
+			s.Backoff = specs.BackoffLinear
+			Expect(s.SetBackoff()).Should(BeNil())
+			Expect(s.GetBackoffPolicy().Next(3, 0)).Should(Equal(30 * time.Millisecond))
+		})
+
+		It("Invalid options", func() {
+			s := specs.NewRestService("service1")
+			Expect(s.SetBackoff()).ShouldNot(BeNil())
+			s.SetOption(specs.ServiceBackoff, specs.BackoffConstant)
+			s.SetOption(specs.ServiceBackoffMultiplier, "x")
+			Expect(s.SetBackoff()).ShouldNot(BeNil())
+			s.SetOption(specs.ServiceBackoffMultiplier, "1")
+			Expect(s.SetBackoff()).ShouldNot(BeNil())
+			s.SetOption(specs.ServiceBackoffMultiplier, "0.5")
+			Expect(s.SetBackoff()).ShouldNot(BeNil())
+			s.SetOption(specs.ServiceBackoffMultiplier, "1.5")
+			Expect(s.SetBackoff()).Should(BeNil())
+		})
+	})
+
+})
@@ -0,0 +1,192 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Max size of the not seekable bodies kept in memory. The
+// bigger bodies are spooled to a temporary file.
+var BodySpoolMemoryLimit int64 = 1024 * 1024
+
+// requestBody describes a body that could be replayed on
+// every attempt and redirect of the ticket.
+type requestBody struct {
+	getBody     func() (io.ReadCloser, error)
+	size        int64
+	contentType string
+	spoolFile   string
+}
+
+func (b *requestBody) cleanup() {
+	if b != nil && b.spoolFile != "" {
+		os.Remove(b.spoolFile)
+		b.spoolFile = ""
+	}
+}
+
+func (t *RestTicket) HasBody() bool { return t.body != nil }
+
+// SetBodyBytes sets the body of the ticket requests.
+func (t *RestTicket) SetBodyBytes(data []byte) error {
+	return t.setBody(&requestBody{
+		getBody: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+		size: int64(len(data)),
+	})
+}
+
+// SetBodyJSON sets the body with the JSON encoding of the value
+// in input and the Content-Type header.
+func (t *RestTicket) SetBodyJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error on encode body: %s", err.Error())
+	}
+	return t.setBody(&requestBody{
+		getBody: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+		size:        int64(len(data)),
+		contentType: "application/json",
+	})
+}
+
+// SetBodyFile sets the body with the content of the file. The file
+// is opened again on every attempt with the size of the file opened.
+func (t *RestTicket) SetBodyFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("error on stat file %s: %s", path, err.Error())
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("file %s is not a regular file", path)
+	}
+	return t.setBody(&requestBody{
+		getBody: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		size: info.Size(),
+	})
+}
+
+// SetBodyReader sets the body with the content of the reader. The
+// readers that implement io.ReaderAt and io.Seeker are read from the
+// current offset on every attempt, the others are spooled in memory
+// or in a temporary file of the spool directory of the service removed
+// by Rip.
+func (t *RestTicket) SetBodyReader(r io.Reader) error {
+	if rs, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		start, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("error on seek body: %s", err.Error())
+		}
+		end, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("error on seek body: %s", err.Error())
+		}
+		return t.setBody(&requestBody{
+			getBody: func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(rs, start, end-start)), nil
+			},
+			size: end - start,
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	n, err := io.CopyN(buf, r, BodySpoolMemoryLimit+1)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error on read body: %s", err.Error())
+	}
+	if n <= BodySpoolMemoryLimit {
+		return t.SetBodyBytes(buf.Bytes())
+	}
+
+	spoolDir := ""
+	if t.Service != nil {
+		spoolDir = t.Service.BodySpoolDir
+	}
+	f, err := os.CreateTemp(spoolDir, "restguard-body-*")
+	if err != nil {
+		return fmt.Errorf("error on create spool file: %s", err.Error())
+	}
+	spoolFile := f.Name()
+	size, err := io.Copy(f, io.MultiReader(buf, r))
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(spoolFile)
+		return fmt.Errorf("error on write spool file %s: %s", spoolFile, err.Error())
+	}
+
+	return t.setBody(&requestBody{
+		getBody: func() (io.ReadCloser, error) {
+			return os.Open(spoolFile)
+		},
+		size:      size,
+		spoolFile: spoolFile,
+	})
+}
+
+func (t *RestTicket) setBody(b *requestBody) error {
+	t.body.cleanup()
+	t.body = b
+	if t.Request != nil {
+		return t.ApplyBody(t.Request)
+	}
+	return nil
+}
+
+// ApplyBody sets the body of the ticket to the request with
+// GetBody and ContentLength. The ContentLength of the file bodies
+// is the size of the file opened.
+func (t *RestTicket) ApplyBody(req *http.Request) error {
+	if t.body == nil {
+		return nil
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	body, err := t.body.getBody()
+	if err != nil {
+		return fmt.Errorf("error on open body: %s", err.Error())
+	}
+	size := t.body.size
+	if f, ok := body.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("error on stat body: %s", err.Error())
+		}
+		size = info.Size()
+	}
+	if size == 0 {
+		body.Close()
+		req.Body = http.NoBody
+		req.GetBody = func() (io.ReadCloser, error) { return http.NoBody, nil }
+	} else {
+		req.Body = body
+		req.GetBody = t.body.getBody
+	}
+	req.ContentLength = size
+	if t.body.contentType != "" && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", t.body.contentType)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreaker tracks the outcomes of the requests sent to a node.
+// After FailureThreshold consecutive failures the breaker is opened
+// and the node is skipped for OpenDuration. Then the breaker permits
+// HalfOpenProbes requests: if all of them succeed the breaker is closed
+// again, otherwise it's reopened.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+	HalfOpenProbes   int
+
+	// Called on every state change outside of the breaker lock.
+	StateCb func(from, to BreakerState)
+
+	mutex     sync.Mutex
+	state     BreakerState
+	failures  int
+	successes int
+	probes    int
+	changedAt time.Time
+	probedAt  time.Time
+}
+
+func NewCircuitBreaker(threshold int, openDuration time.Duration, probes int) *CircuitBreaker {
+	if probes <= 0 {
+		probes = 1
+	}
+	return &CircuitBreaker{
+		FailureThreshold: threshold,
+		OpenDuration:     openDuration,
+		HalfOpenProbes:   probes,
+		state:            BreakerClosed,
+		changedAt:        time.Now(),
+	}
+}
+
+// setState must be called with the lock acquired. It returns
+// the function to call after the unlock to notify the change.
+func (b *CircuitBreaker) setState(s BreakerState) func() {
+	if b.state == s {
+		return nil
+	}
+	from := b.state
+	b.state = s
+	b.changedAt = time.Now()
+	b.failures = 0
+	b.successes = 0
+	b.probes = 0
+	if b.StateCb == nil {
+		return nil
+	}
+	cb := b.StateCb
+	return func() { cb(from, s) }
+}
+
+func notify(f func()) {
+	if f != nil {
+		f()
+	}
+}
+
+func (b *CircuitBreaker) GetState() BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// Ready returns true if the node could be used for a new request.
+// An open breaker is moved to half-open when the open duration
+// is elapsed.
+func (b *CircuitBreaker) Ready() bool {
+	var f func()
+	b.mutex.Lock()
+	ans := false
+	switch b.state {
+	case BreakerClosed:
+		ans = true
+	case BreakerOpen:
+		if time.Since(b.changedAt) >= b.OpenDuration {
+			f = b.setState(BreakerHalfOpen)
+			ans = true
+		}
+	case BreakerHalfOpen:
+		if b.probes >= b.HalfOpenProbes &&
+			time.Since(b.probedAt) >= b.OpenDuration {
+			// The probes are been assigned but never completed.
+			b.probes = b.successes
+		}
+		ans = b.probes < b.HalfOpenProbes
+	}
+	b.mutex.Unlock()
+	notify(f)
+	return ans
+}
+
+// Acquire registers a new probe when the breaker is half-open.
+func (b *CircuitBreaker) Acquire() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.probes++
+		b.probedAt = time.Now()
+	}
+}
+
+func (b *CircuitBreaker) OnSuccess() {
+	var f func()
+	b.mutex.Lock()
+	switch b.state {
+	case BreakerClosed:
+		b.failures = 0
+	case BreakerHalfOpen:
+		b.successes++
+		if b.successes >= b.HalfOpenProbes {
+			f = b.setState(BreakerClosed)
+		}
+	}
+	b.mutex.Unlock()
+	notify(f)
+}
+
+func (b *CircuitBreaker) OnFailure() {
+	var f func()
+	b.mutex.Lock()
+	switch b.state {
+	case BreakerClosed:
+		b.failures++
+		if b.failures >= b.FailureThreshold {
+			f = b.setState(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		f = b.setState(BreakerOpen)
+	}
+	b.mutex.Unlock()
+	notify(f)
+}
+
+const defaultBreakerOpenMs = 30000
+
+func (s *RestService) HasCircuitBreaker() bool {
+	return s.BreakerFailureThreshold > 0
+}
+
+// SetCircuitBreaker reads the circuit breaker options of the service.
+// The options override the Breaker* fields values.
+func (s *RestService) SetCircuitBreaker() error {
+	opts := []struct {
+		key   string
+		field *int
+	}{
+		{ServiceBreakerThreshold, &s.BreakerFailureThreshold},
+		{ServiceBreakerOpenMs, &s.BreakerOpenMs},
+		{ServiceBreakerProbes, &s.BreakerHalfOpenProbes},
+	}
+
+	for _, o := range opts {
+		v, err := s.GetOption(o.key)
+		if err != nil {
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid %s option: %s", o.key, err.Error())
+		}
+		*o.field = i
+	}
+
+	if !s.HasCircuitBreaker() {
+		return fmt.Errorf("No circuit breaker threshold available")
+	}
+	return nil
+}
+
+// GetBreaker returns the circuit breaker of the node or nil if
+// the circuit breaker is disabled.
+func (s *RestService) GetBreaker(n *RestNode) *CircuitBreaker {
+	if !s.HasCircuitBreaker() || n == nil {
+		return nil
+	}
+
+	s.breakersMutex.Lock()
+	defer s.breakersMutex.Unlock()
+
+	if s.breakers == nil {
+		s.breakers = make(map[string]*CircuitBreaker, 0)
+	}
+
+	b, ok := s.breakers[n.Name]
+	if !ok {
+		openMs := s.BreakerOpenMs
+		if openMs <= 0 {
+			openMs = defaultBreakerOpenMs
+		}
+		b = NewCircuitBreaker(s.BreakerFailureThreshold,
+			time.Duration(openMs)*time.Millisecond,
+			s.BreakerHalfOpenProbes)
+		b.StateCb = func(from, to BreakerState) {
+			if s.BreakerStateCb != nil {
+				s.BreakerStateCb(s, n, from, to)
+			}
+		}
+		s.breakers[n.Name] = b
+	}
+
+	return b
+}
+
+// GetBreakerState returns the state of the circuit breaker of the node.
+// Without circuit breaker the node is always closed.
+func (s *RestService) GetBreakerState(n *RestNode) BreakerState {
+	b := s.GetBreaker(n)
+	if b == nil {
+		return BreakerClosed
+	}
+	return b.GetState()
+}
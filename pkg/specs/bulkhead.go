@@ -0,0 +1,116 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var ErrBulkheadFull = errors.New("The bulkhead of the service is full.")
+
+// Bulkhead limits the number of tickets in flight of a service.
+// The tickets over the limit wait in a bounded queue. A ticket holds
+// the slot until the body of the response is closed.
+type Bulkhead struct {
+	slots    chan struct{}
+	maxQueue int64
+	waitTime time.Duration
+	queued   atomic.Int64
+}
+
+// NewBulkhead returns a bulkhead with maxConcurrent tickets in flight
+// and maxQueue tickets in wait. A zero waitTime means no timeout.
+func NewBulkhead(maxConcurrent, maxQueue int, waitTime time.Duration) *Bulkhead {
+	return &Bulkhead{
+		slots:    make(chan struct{}, maxConcurrent),
+		maxQueue: int64(maxQueue),
+		waitTime: waitTime,
+	}
+}
+
+// Acquire reserves a slot for a ticket. It returns ErrBulkheadFull
+// when the queue is full or the wait timeout expires and the context
+// error on cancellation.
+func (b *Bulkhead) Acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if b.queued.Add(1) > b.maxQueue {
+		b.queued.Add(-1)
+		return ErrBulkheadFull
+	}
+	defer b.queued.Add(-1)
+
+	var timeout <-chan time.Time
+	if b.waitTime > 0 {
+		timer := time.NewTimer(b.waitTime)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		return ErrBulkheadFull
+	}
+}
+
+func (b *Bulkhead) Release()         { <-b.slots }
+func (b *Bulkhead) GetInFlight() int { return len(b.slots) }
+func (b *Bulkhead) GetQueued() int   { return int(b.queued.Load()) }
+
+func (s *RestService) HasBulkhead() bool      { return s.Bulkhead != nil }
+func (s *RestService) GetBulkhead() *Bulkhead { return s.Bulkhead }
+
+// SetBulkhead creates the bulkhead of the service.
+// The options override the Bulkhead* fields values.
+func (s *RestService) SetBulkhead() error {
+	if v, err := s.GetOption(ServiceBulkheadMaxConcurrent); err == nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid bulkhead max concurrent option: %s", err.Error())
+		}
+		s.BulkheadMaxConcurrent = n
+	}
+	if v, err := s.GetOption(ServiceBulkheadMaxQueue); err == nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid bulkhead max queue option: %s", err.Error())
+		}
+		s.BulkheadMaxQueue = n
+	}
+	if v, err := s.GetOption(ServiceBulkheadWaitMs); err == nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid bulkhead wait option: %s", err.Error())
+		}
+		s.BulkheadWaitMs = n
+	}
+
+	if s.BulkheadMaxConcurrent <= 0 {
+		return fmt.Errorf("Invalid bulkhead max concurrent %d", s.BulkheadMaxConcurrent)
+	}
+	if s.BulkheadMaxQueue < 0 {
+		return fmt.Errorf("Invalid bulkhead max queue %d", s.BulkheadMaxQueue)
+	}
+	if s.BulkheadWaitMs < 0 {
+		return fmt.Errorf("Invalid bulkhead wait %d", s.BulkheadWaitMs)
+	}
+
+	s.Bulkhead = NewBulkhead(s.BulkheadMaxConcurrent, s.BulkheadMaxQueue,
+		time.Duration(s.BulkheadWaitMs)*time.Millisecond)
+	return nil
+}
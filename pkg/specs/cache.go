@@ -0,0 +1,281 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CacheMemory = "memory"
+	CacheDisk   = "disk"
+
+	// The status of the ticket about the response cache.
+	CacheStatusMiss        = "miss"
+	CacheStatusHit         = "hit"
+	CacheStatusRevalidated = "revalidated"
+)
+
+// CacheMaxBodySize is the max size of the bodies stored in the cache.
+var CacheMaxBodySize int64 = 10 * 1024 * 1024
+
+// ResponseCache is the storage of the responses cached.
+type ResponseCache interface {
+	// Get returns the entry of the key or nil if not present.
+	Get(key string) (*CacheEntry, error)
+	Set(e *CacheEntry) error
+	Delete(key string) error
+}
+
+// CacheEntry is a response stored in the cache. The entries are
+// not modified after the store.
+type CacheEntry struct {
+	Key        string      `json:"key"`
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// The values of the request headers selected by Vary.
+	VaryHeaders map[string]string `json:"vary_headers,omitempty"`
+
+	RequestTime  time.Time `json:"request_time"`
+	ResponseTime time.Time `json:"response_time"`
+}
+
+// NewCacheEntry creates the entry of the response. The request and
+// response times are used to compute the age of the entry.
+func NewCacheEntry(key string, req *http.Request, resp *http.Response,
+	body []byte, reqTime, respTime time.Time) *CacheEntry {
+	ans := &CacheEntry{
+		Key:          key,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		Body:         body,
+		RequestTime:  reqTime,
+		ResponseTime: respTime,
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if ans.VaryHeaders == nil {
+			ans.VaryHeaders = make(map[string]string, 0)
+		}
+		ans.VaryHeaders[name] = req.Header.Get(name)
+	}
+	return ans
+}
+
+// parseCacheControl returns the directives of the Cache-Control
+// header with the lowercase names.
+func parseCacheControl(h http.Header) map[string]string {
+	ans := make(map[string]string, 0)
+	for _, v := range h.Values("Cache-Control") {
+		for _, d := range strings.Split(v, ",") {
+			d = strings.TrimSpace(d)
+			if d == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(d, "=")
+			ans[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ans
+}
+
+func varyHeaders(h http.Header) []string {
+	ans := []string{}
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name != "" {
+				ans = append(ans, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return ans
+}
+
+func parseSeconds(v string) (time.Duration, bool) {
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// heuristicStatus contains the status codes cacheable without
+// explicit freshness information.
+var heuristicStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// IsCacheableRequest checks if the response of the request could
+// be stored or served from the cache.
+func IsCacheableRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return false
+	}
+	_, noStore := parseCacheControl(req.Header)["no-store"]
+	return !noStore
+}
+
+// IsCacheableResponse checks if the response of the request could be
+// stored by a private cache (RFC 9111, section 3).
+func IsCacheableResponse(req *http.Request, resp *http.Response) bool {
+	if !IsCacheableRequest(req) || !heuristicStatus[resp.StatusCode] {
+		return false
+	}
+	if _, noStore := parseCacheControl(resp.Header)["no-store"]; noStore {
+		return false
+	}
+	for _, name := range varyHeaders(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchVary checks if the request has the same values of the
+// headers selected by Vary.
+func (e *CacheEntry) MatchVary(req *http.Request) bool {
+	for name, v := range e.VaryHeaders {
+		if req.Header.Get(name) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *CacheEntry) date() time.Time {
+	if d, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return d
+	}
+	return e.ResponseTime
+}
+
+// GetAge returns the current age of the entry (RFC 9111, section 4.2.3).
+func (e *CacheEntry) GetAge(now time.Time) time.Duration {
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	ageValue, _ := parseSeconds(e.Header.Get("Age"))
+	correctedAge := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if apparentAge > correctedAge {
+		correctedAge = apparentAge
+	}
+	return correctedAge + now.Sub(e.ResponseTime)
+}
+
+// GetFreshnessLifetime returns the freshness lifetime of the entry
+// (RFC 9111, section 4.2.1). Without explicit expiration the 10% of
+// the time since the last modification is used.
+func (e *CacheEntry) GetFreshnessLifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if v, ok := cc["max-age"]; ok {
+		d, _ := parseSeconds(v)
+		return d
+	}
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// An invalid date means already expired.
+			return 0
+		}
+		return expires.Sub(e.date())
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		if d := e.date().Sub(lm); d > 0 {
+			return d / 10
+		}
+	}
+	return 0
+}
+
+// IsFresh checks if the entry could be served without revalidation.
+// The directives of the request in input are considered if not nil.
+func (e *CacheEntry) IsFresh(req *http.Request, now time.Time) bool {
+	if _, noCache := parseCacheControl(e.Header)["no-cache"]; noCache {
+		return false
+	}
+	age := e.GetAge(now)
+	if req != nil {
+		cc := parseCacheControl(req.Header)
+		if _, noCache := cc["no-cache"]; noCache {
+			return false
+		}
+		if len(cc) == 0 && req.Header.Get("Pragma") == "no-cache" {
+			return false
+		}
+		if v, ok := cc["max-age"]; ok {
+			if maxAge, valid := parseSeconds(v); valid && age > maxAge {
+				return false
+			}
+		}
+	}
+	return age < e.GetFreshnessLifetime()
+}
+
+// HasValidators checks if the entry could be revalidated.
+func (e *CacheEntry) HasValidators() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+// SetConditionalHeaders sets the headers to revalidate the entry.
+func (e *CacheEntry) SetConditionalHeaders(req *http.Request) {
+	if etag := e.Header.Get("ETag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		req.Header.Set("If-Modified-Since", lm)
+	}
+}
+
+// Revalidate returns a new entry with the headers of the 304
+// response in input (RFC 9111, section 4.3.4).
+func (e *CacheEntry) Revalidate(resp *http.Response, reqTime, respTime time.Time) *CacheEntry {
+	ans := &CacheEntry{
+		Key:          e.Key,
+		StatusCode:   e.StatusCode,
+		Header:       e.Header.Clone(),
+		Body:         e.Body,
+		VaryHeaders:  e.VaryHeaders,
+		RequestTime:  reqTime,
+		ResponseTime: respTime,
+	}
+	// The Age of the stored response is no more valid.
+	ans.Header.Del("Age")
+	for name, values := range resp.Header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		ans.Header[name] = append([]string{}, values...)
+	}
+	return ans
+}
+
+// GetResponse returns a new response of the request with the
+// content of the entry.
+func (e *CacheEntry) GetResponse(req *http.Request, now time.Time) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", fmt.Sprintf("%d", int64(e.GetAge(now)/time.Second)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Response Cache Test", func() {
+
+	now := time.Now().Truncate(time.Second)
+
+	newEntry := func(header http.Header) *specs.CacheEntry {
+		req, _ := http.NewRequest("GET", "http://localhost/meta", nil)
+		resp := &http.Response{StatusCode: http.StatusOK, Header: header}
+		return specs.NewCacheEntry("meta", req, resp, []byte("data"), now, now)
+	}
+
+	It("Compute the freshness lifetime", func() {
+		e := newEntry(http.Header{"Cache-Control": []string{"public, max-age=30"}})
+		Expect(e.GetFreshnessLifetime()).Should(Equal(30 * time.Second))
+		Expect(e.IsFresh(nil, now.Add(20*time.Second))).Should(BeTrue())
+		Expect(e.IsFresh(nil, now.Add(40*time.Second))).Should(BeFalse())
+
+		e = newEntry(http.Header{
+			"Date":    []string{now.UTC().Format(http.TimeFormat)},
+			"Expires": []string{now.Add(time.Minute).UTC().Format(http.TimeFormat)},
+		})
+		Expect(e.GetFreshnessLifetime()).Should(Equal(time.Minute))
+
+		e = newEntry(http.Header{"Expires": []string{"0"}})
+		Expect(e.GetFreshnessLifetime()).Should(Equal(time.Duration(0)))
+
+		// The 10% of the time since the last modification.
+		e = newEntry(http.Header{
+			"Date":          []string{now.UTC().Format(http.TimeFormat)},
+			"Last-Modified": []string{now.Add(-10 * time.Hour).UTC().Format(http.TimeFormat)},
+		})
+		Expect(e.GetFreshnessLifetime()).Should(Equal(time.Hour))
+	})
+
+	It("Compute the age with the Age header", func() {
+		e := newEntry(http.Header{
+			"Cache-Control": []string{"max-age=60"},
+			"Age":           []string{"50"},
+		})
+		Expect(e.GetAge(now.Add(5 * time.Second))).Should(Equal(55 * time.Second))
+		Expect(e.IsFresh(nil, now.Add(5*time.Second))).Should(BeTrue())
+		Expect(e.IsFresh(nil, now.Add(15*time.Second))).Should(BeFalse())
+	})
+
+	It("Honor the directives of the request", func() {
+		e := newEntry(http.Header{"Cache-Control": []string{"max-age=60"}})
+		req, _ := http.NewRequest("GET", "http://localhost/meta", nil)
+		Expect(e.IsFresh(req, now.Add(20*time.Second))).Should(BeTrue())
+
+		req.Header.Set("Cache-Control", "max-age=10")
+		Expect(e.IsFresh(req, now.Add(20*time.Second))).Should(BeFalse())
+
+		req.Header.Set("Cache-Control", "no-cache")
+		Expect(e.IsFresh(req, now)).Should(BeFalse())
+
+		req.Header.Set("Cache-Control", "no-store")
+		Expect(specs.IsCacheableRequest(req)).Should(BeFalse())
+	})
+
+	It("Check the responses cacheable", func() {
+		req, _ := http.NewRequest("GET", "http://localhost/meta", nil)
+		resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		Expect(specs.IsCacheableResponse(req, resp)).Should(BeTrue())
+
+		resp.Header.Set("Vary", "*")
+		Expect(specs.IsCacheableResponse(req, resp)).Should(BeFalse())
+
+		resp = &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}}
+		Expect(specs.IsCacheableResponse(req, resp)).Should(BeFalse())
+
+		req, _ = http.NewRequest("POST", "http://localhost/meta", nil)
+		resp = &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+		Expect(specs.IsCacheableResponse(req, resp)).Should(BeFalse())
+	})
+
+	It("Evict the least recently used entries", func() {
+		c := specs.NewMemoryCache(2)
+		for _, key := range []string{"a", "b"} {
+			Expect(c.Set(&specs.CacheEntry{Key: key})).Should(BeNil())
+		}
+		e, _ := c.Get("a")
+		Expect(e).ShouldNot(BeNil())
+		Expect(c.Set(&specs.CacheEntry{Key: "c"})).Should(BeNil())
+
+		Expect(c.Len()).Should(Equal(2))
+		e, _ = c.Get("b")
+		Expect(e).Should(BeNil())
+		e, _ = c.Get("a")
+		Expect(e).ShouldNot(BeNil())
+	})
+
+})
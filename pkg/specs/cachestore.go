@@ -0,0 +1,194 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// MemoryCache stores the entries in memory. With maxEntries greater
+// than zero the least recently used entries are evicted.
+type MemoryCache struct {
+	mutex      sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element, 0),
+		lru:        list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (*CacheEntry, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*CacheEntry), nil
+}
+
+func (c *MemoryCache) Set(e *CacheEntry) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[e.Key]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return nil
+	}
+	c.entries[e.Key] = c.lru.PushFront(e)
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*CacheEntry).Key)
+	}
+	return nil
+}
+
+func (c *MemoryCache) Delete(key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+func (c *MemoryCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// DiskCache stores the entries as JSON files in a directory. The
+// entries survive to the restart of the process.
+type DiskCache struct {
+	dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if dir == "" {
+		return nil, errors.New("The directory of the disk cache is not set.")
+	}
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error on create cache directory %s: %s", dir, err.Error())
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (c *DiskCache) GetDir() string { return c.dir }
+
+func (c *DiskCache) entryPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *DiskCache) Get(key string) (*CacheEntry, error) {
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ans := &CacheEntry{}
+	if err := json.Unmarshal(data, ans); err != nil || ans.Key != key {
+		// A corrupted entry is a miss.
+		os.Remove(c.entryPath(key))
+		return nil, nil
+	}
+	return ans, nil
+}
+
+func (c *DiskCache) Set(e *CacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// The entry is replaced atomically for the concurrent readers.
+	fd, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	_, err = fd.Write(data)
+	if cerr := fd.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(fd.Name(), c.entryPath(e.Key))
+	}
+	if err != nil {
+		os.Remove(fd.Name())
+		return fmt.Errorf("error on store cache entry: %s", err.Error())
+	}
+	return nil
+}
+
+func (c *DiskCache) Delete(key string) error {
+	err := os.Remove(c.entryPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *RestService) HasCache() bool { return s.ResponseCache != nil }
+
+func (s *RestService) GetCache() ResponseCache { return s.ResponseCache }
+
+// SetResponseCache sets a custom cache backend.
+func (s *RestService) SetResponseCache(c ResponseCache) { s.ResponseCache = c }
+
+// SetCache creates the response cache of the service from the cache
+// options or from the Cache* fields. The options override the fields
+// values.
+func (s *RestService) SetCache() error {
+	if v, err := s.GetOption(ServiceCache); err == nil {
+		s.Cache = v
+	}
+	if v, err := s.GetOption(ServiceCacheDir); err == nil {
+		s.CacheDir = v
+	}
+	if v, err := s.GetOption(ServiceCacheMaxEntries); err == nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid cache max entries option: %s", err.Error())
+		}
+		s.CacheMaxEntries = n
+	}
+
+	switch s.Cache {
+	case CacheMemory:
+		s.ResponseCache = NewMemoryCache(s.CacheMaxEntries)
+	case CacheDisk:
+		c, err := NewDiskCache(s.CacheDir)
+		if err != nil {
+			return err
+		}
+		s.ResponseCache = c
+	case "":
+		return fmt.Errorf("No cache backend available")
+	default:
+		return fmt.Errorf("Invalid cache backend %s", s.Cache)
+	}
+	return nil
+}
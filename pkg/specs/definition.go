@@ -7,12 +7,40 @@ package specs
 import (
 	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
 const (
-	ServiceRateLimiter string = "rate_limiter"
+	ServiceRateLimiter           string = "rate_limiter"
+	ServiceRateLimiterMode       string = "rate_limiter_mode"
+	ServiceRateLimiterMin        string = "rate_limiter_min"
+	ServiceRateLimiterMax        string = "rate_limiter_max"
+	ServiceRateLimiterIncrease   string = "rate_limiter_increase"
+	ServiceRateLimiterDecrease   string = "rate_limiter_decrease"
+	ServiceBackoff               string = "backoff"
+	ServiceBackoffMaxIntervalMs  string = "backoff_max_interval_ms"
+	ServiceBackoffMultiplier     string = "backoff_multiplier"
+	ServiceBreakerThreshold      string = "breaker_failure_threshold"
+	ServiceBreakerOpenMs         string = "breaker_open_ms"
+	ServiceBreakerProbes         string = "breaker_halfopen_probes"
+	ServiceSelector              string = "selector"
+	ServiceRetryAfterMode        string = "retry_after_mode"
+	ServiceRetryAfterMaxMs       string = "retry_after_max_ms"
+	ServiceRetryPolicy           string = "retry_policy"
+	ServiceIdempotencyKey        string = "idempotency_key"
+	ServiceHedgeDelayMs          string = "hedge_delay_ms"
+	ServiceHedgePercentile       string = "hedge_percentile"
+	ServiceHedgeMaxInFlight      string = "hedge_max_inflight"
+	ServiceBulkheadMaxConcurrent string = "bulkhead_max_concurrent"
+	ServiceBulkheadMaxQueue      string = "bulkhead_max_queue"
+	ServiceBulkheadWaitMs        string = "bulkhead_wait_ms"
+	ServiceCache                 string = "cache"
+	ServiceCacheDir              string = "cache_dir"
+	ServiceCacheMaxEntries       string = "cache_max_entries"
 )
 
 type RestTicket struct {
@@ -25,9 +53,18 @@ type RestTicket struct {
 	Node        *RestNode      `json:"node,omitempty" yaml:"node,omitempty" mapstructure:"node,omitempty"`
 	FailedNodes RestNodes      `json:"failed_nodes,omitempty" yaml:"failed_nodes,omitempty" mapstructure:"failed_nodes,omitempty"`
 
+	// The last interval waited between two retries.
+	LastInterval time.Duration `json:"-" yaml:"-" mapstructure:"-"`
+	// The status of the response cache: miss, hit or revalidated.
+	// Empty if the cache is not used.
+	CacheStatus string `json:"cache_status,omitempty" yaml:"cache_status,omitempty" mapstructure:"cache_status,omitempty"`
+
 	RequestBodyCb  func(t *RestTicket) (bool, io.ReadCloser, error) `json:"-" yaml:"-" mapstructure:"-"`
 	RequestCloseCb func(t *RestTicket)                              `json:"-" yaml:"-" mapstructure:"-"`
 	Closure        map[string]interface{}                           `json:"-" yaml:"-" mapstructure:"-"`
+
+	// The body replayed on every attempt.
+	body *requestBody
 }
 
 type RestNode struct {
@@ -36,6 +73,27 @@ type RestNode struct {
 	BaseUrl string `json:"base_url" yaml:"base_url" mapstructure:"base_url"`
 	Schema  string `json:"schema,omitempty" yaml:"schema,omitempty" mapstructure:"schema,omitempty"`
 	Ssl     bool   `json:"ssl,omitempty" yaml:"ssl,omitempty" mapstructure:"ssl,omitempty"`
+	Weight  int    `json:"weight,omitempty" yaml:"weight,omitempty" mapstructure:"weight,omitempty"`
+	// The rate limit spec of the node, applied over the
+	// rate limiter of the service.
+	RateLimit string `json:"rate_limit,omitempty" yaml:"rate_limit,omitempty" mapstructure:"rate_limit,omitempty"`
+
+	// The authentication of the node. It overrides the
+	// authentication of the service.
+	Auth *RestAuth `json:"auth,omitempty" yaml:"auth,omitempty" mapstructure:"auth,omitempty"`
+	// The TLS profile of the node. It overrides the
+	// TLS profile of the service.
+	TLS *RestTLS `json:"tls,omitempty" yaml:"tls,omitempty" mapstructure:"tls,omitempty"`
+
+	RateLimiter   *rate.Limiter `json:"-" yaml:"-" mapstructure:"-"`
+	Authenticator Authenticator `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Set by the health checker when the node doesn't pass the probes.
+	unhealthy atomic.Bool
+	// Number of requests in progress.
+	outstanding atomic.Int64
+	// Unix time in nanoseconds until the node is cooling-down.
+	cooldown atomic.Int64
 }
 
 type RestNodes []*RestNode
@@ -46,11 +104,82 @@ type RestService struct {
 	Retries         int         `json:"retries,omitempty" yaml:"retries,omitempty" mapstructure:"retries,omitempty"`
 	RetryIntervalMs int         `json:"retry_interval_ms,omitempty" yaml:"retry_interval_ms,omitempty" mapstructure:"retry_interval_ms,omitempty"`
 
+	// The backoff policy name and parameters. RetryIntervalMs is used
+	// as initial interval.
+	Backoff              string  `json:"backoff,omitempty" yaml:"backoff,omitempty" mapstructure:"backoff,omitempty"`
+	BackoffMaxIntervalMs int     `json:"backoff_max_interval_ms,omitempty" yaml:"backoff_max_interval_ms,omitempty" mapstructure:"backoff_max_interval_ms,omitempty"`
+	BackoffMultiplier    float64 `json:"backoff_multiplier,omitempty" yaml:"backoff_multiplier,omitempty" mapstructure:"backoff_multiplier,omitempty"`
+
+	// The circuit breaker of the nodes is enabled when the failure
+	// threshold is greater than zero.
+	BreakerFailureThreshold int `json:"breaker_failure_threshold,omitempty" yaml:"breaker_failure_threshold,omitempty" mapstructure:"breaker_failure_threshold,omitempty"`
+	BreakerOpenMs           int `json:"breaker_open_ms,omitempty" yaml:"breaker_open_ms,omitempty" mapstructure:"breaker_open_ms,omitempty"`
+	BreakerHalfOpenProbes   int `json:"breaker_halfopen_probes,omitempty" yaml:"breaker_halfopen_probes,omitempty" mapstructure:"breaker_halfopen_probes,omitempty"`
+
+	// The handling of the Retry-After header of the 429 and 503
+	// responses: wait or cooldown. Empty to ignore the header.
+	RetryAfterMode  string `json:"retry_after_mode,omitempty" yaml:"retry_after_mode,omitempty" mapstructure:"retry_after_mode,omitempty"`
+	RetryAfterMaxMs int    `json:"retry_after_max_ms,omitempty" yaml:"retry_after_max_ms,omitempty" mapstructure:"retry_after_max_ms,omitempty"`
+
+	// The retry policy of the not idempotent methods: always,
+	// idempotent or connect-errors. Default is always.
+	RetryPolicy string `json:"retry_policy,omitempty" yaml:"retry_policy,omitempty" mapstructure:"retry_policy,omitempty"`
+	// Set the Idempotency-Key header with the ticket Id.
+	IdempotencyKey bool `json:"idempotency_key,omitempty" yaml:"idempotency_key,omitempty" mapstructure:"idempotency_key,omitempty"`
+
+	// The hedging delay is fixed or the percentile of the latencies
+	// observed. HedgeMaxInFlight is the max number of extra requests
+	// in flight of the service.
+	HedgeDelayMs     int     `json:"hedge_delay_ms,omitempty" yaml:"hedge_delay_ms,omitempty" mapstructure:"hedge_delay_ms,omitempty"`
+	HedgePercentile  float64 `json:"hedge_percentile,omitempty" yaml:"hedge_percentile,omitempty" mapstructure:"hedge_percentile,omitempty"`
+	HedgeMaxInFlight int     `json:"hedge_max_inflight,omitempty" yaml:"hedge_max_inflight,omitempty" mapstructure:"hedge_max_inflight,omitempty"`
+
+	// The bulkhead limits the tickets in flight of the service. The
+	// tickets over the limit wait in a queue of BulkheadMaxQueue tickets
+	// for BulkheadWaitMs milliseconds.
+	BulkheadMaxConcurrent int `json:"bulkhead_max_concurrent,omitempty" yaml:"bulkhead_max_concurrent,omitempty" mapstructure:"bulkhead_max_concurrent,omitempty"`
+	BulkheadMaxQueue      int `json:"bulkhead_max_queue,omitempty" yaml:"bulkhead_max_queue,omitempty" mapstructure:"bulkhead_max_queue,omitempty"`
+	BulkheadWaitMs        int `json:"bulkhead_wait_ms,omitempty" yaml:"bulkhead_wait_ms,omitempty" mapstructure:"bulkhead_wait_ms,omitempty"`
+
+	// The response cache of the GET requests: memory or disk.
+	// CacheDir is the directory of the disk cache.
+	Cache           string `json:"cache,omitempty" yaml:"cache,omitempty" mapstructure:"cache,omitempty"`
+	CacheDir        string `json:"cache_dir,omitempty" yaml:"cache_dir,omitempty" mapstructure:"cache_dir,omitempty"`
+	CacheMaxEntries int    `json:"cache_max_entries,omitempty" yaml:"cache_max_entries,omitempty" mapstructure:"cache_max_entries,omitempty"`
+
+	// The directory of the spool files of the request bodies. The
+	// default directory for temporary files is used if empty.
+	BodySpoolDir string `json:"body_spool_dir,omitempty" yaml:"body_spool_dir,omitempty" mapstructure:"body_spool_dir,omitempty"`
+
+	// The name of the node selector strategy.
+	Selector string `json:"selector,omitempty" yaml:"selector,omitempty" mapstructure:"selector,omitempty"`
+
+	HealthCheck *RestHealthCheck `json:"health_check,omitempty" yaml:"health_check,omitempty" mapstructure:"health_check,omitempty"`
+
+	Auth *RestAuth `json:"auth,omitempty" yaml:"auth,omitempty" mapstructure:"auth,omitempty"`
+	TLS  *RestTLS  `json:"tls,omitempty" yaml:"tls,omitempty" mapstructure:"tls,omitempty"`
+
 	Options map[string]string `json:"options,omitempty" yaml:"options,omitempty" mapstructure:"options,omitempty"`
 
 	RespValidatorCb func(t *RestTicket) (bool, error) `json:"-" yaml:"-" mapstructure:"-"`
 
-	RateLimiter *rate.Limiter `"json:"-" yaml:"-" mapstructure:"-"`
+	RateLimiter     *rate.Limiter    `json:"-" yaml:"-" mapstructure:"-"`
+	AdaptiveLimiter *AdaptiveLimiter `json:"-" yaml:"-" mapstructure:"-"`
+	BackoffPolicy   BackoffPolicy    `json:"-" yaml:"-" mapstructure:"-"`
+	NodeSelector    NodeSelector     `json:"-" yaml:"-" mapstructure:"-"`
+	Bulkhead        *Bulkhead        `json:"-" yaml:"-" mapstructure:"-"`
+	Authenticator   Authenticator    `json:"-" yaml:"-" mapstructure:"-"`
+	ResponseCache   ResponseCache    `json:"-" yaml:"-" mapstructure:"-"`
+	Middlewares     []Middleware     `json:"-" yaml:"-" mapstructure:"-"`
+
+	BreakerStateCb func(s *RestService, n *RestNode, from, to BreakerState) `json:"-" yaml:"-" mapstructure:"-"`
+
+	breakers      map[string]*CircuitBreaker
+	breakersMutex sync.Mutex
+
+	latencies      latencyWindow
+	latencyMutex   sync.Mutex
+	hedgesInFlight atomic.Int64
 }
 
 type RestGuardConfig struct {
@@ -71,4 +200,8 @@ type RestArtefact struct {
 	Md5     string `json:"md5,omitempty" yaml:"md5,omitempty" mapstructure:"md5,omitempty"`
 	Sha512  string `json:"sha512,omitempty" yaml:"sha512,omitempty" mapstructure:"sha512,omitempty"`
 	Blake2b string `json:"blake2b,omitempty" yaml:"blake2b,omitempty" mapstructure:"blake2b,omitempty"`
+
+	// The size and the hashes of the stream received when the
+	// artefact is decompressed on download.
+	Compressed *RestArtefact `json:"compressed,omitempty" yaml:"compressed,omitempty" mapstructure:"compressed,omitempty"`
 }
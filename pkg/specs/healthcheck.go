@@ -0,0 +1,89 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"net/http"
+	"time"
+)
+
+type RestHealthCheck struct {
+	Path             string `json:"path,omitempty" yaml:"path,omitempty" mapstructure:"path,omitempty"`
+	Method           string `json:"method,omitempty" yaml:"method,omitempty" mapstructure:"method,omitempty"`
+	IntervalMs       int    `json:"interval_ms,omitempty" yaml:"interval_ms,omitempty" mapstructure:"interval_ms,omitempty"`
+	TimeoutMs        int    `json:"timeout_ms,omitempty" yaml:"timeout_ms,omitempty" mapstructure:"timeout_ms,omitempty"`
+	ExpectedStatus   []int  `json:"expected_status,omitempty" yaml:"expected_status,omitempty" mapstructure:"expected_status,omitempty"`
+	FailureThreshold int    `json:"failure_threshold,omitempty" yaml:"failure_threshold,omitempty" mapstructure:"failure_threshold,omitempty"`
+	SuccessThreshold int    `json:"success_threshold,omitempty" yaml:"success_threshold,omitempty" mapstructure:"success_threshold,omitempty"`
+
+	// Optional validator used in place of the expected status.
+	ValidatorCb func(n *RestNode, resp *http.Response) (bool, error) `json:"-" yaml:"-" mapstructure:"-"`
+}
+
+func NewRestHealthCheck(path string) *RestHealthCheck {
+	return &RestHealthCheck{
+		Path:             path,
+		Method:           "GET",
+		IntervalMs:       10000,
+		TimeoutMs:        5000,
+		ExpectedStatus:   []int{200},
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+	}
+}
+
+func (h *RestHealthCheck) GetMethod() string {
+	if h.Method == "" {
+		return "GET"
+	}
+	return h.Method
+}
+
+func (h *RestHealthCheck) GetInterval() time.Duration {
+	if h.IntervalMs <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(h.IntervalMs) * time.Millisecond
+}
+
+func (h *RestHealthCheck) GetTimeout() time.Duration {
+	if h.TimeoutMs <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(h.TimeoutMs) * time.Millisecond
+}
+
+func (h *RestHealthCheck) GetFailureThreshold() int {
+	if h.FailureThreshold <= 0 {
+		return 1
+	}
+	return h.FailureThreshold
+}
+
+func (h *RestHealthCheck) GetSuccessThreshold() int {
+	if h.SuccessThreshold <= 0 {
+		return 1
+	}
+	return h.SuccessThreshold
+}
+
+// IsValid checks the response of the probe with the validator
+// callback or with the expected status codes.
+func (h *RestHealthCheck) IsValid(n *RestNode, resp *http.Response) (bool, error) {
+	if h.ValidatorCb != nil {
+		return h.ValidatorCb(n, resp)
+	}
+
+	expected := h.ExpectedStatus
+	if len(expected) == 0 {
+		expected = []int{200}
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultHedgeDelay       = 100 * time.Millisecond
+	DefaultHedgeMaxInFlight = 10
+
+	// Number of latencies used to compute the percentile.
+	hedgeLatencySamples = 128
+	// Min number of latencies needed to use the percentile.
+	hedgeMinSamples = 10
+)
+
+// latencyWindow keeps the last latencies observed of the service.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	if len(w.samples) < hedgeLatencySamples {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % hedgeLatencySamples
+}
+
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	if len(w.samples) < hedgeMinSamples {
+		return 0, false
+	}
+	sorted := append([]time.Duration{}, w.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(p/100*float64(len(sorted))+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx], true
+}
+
+func (s *RestService) HasHedging() bool {
+	return s.HedgeDelayMs > 0 || s.HedgePercentile > 0
+}
+
+// ObserveLatency registers the latency of a response of the service
+// used to compute the hedging delay.
+func (s *RestService) ObserveLatency(d time.Duration) {
+	if s.HedgePercentile <= 0 {
+		return
+	}
+	s.latencyMutex.Lock()
+	defer s.latencyMutex.Unlock()
+	s.latencies.add(d)
+}
+
+// GetHedgeDelay returns the time to wait before sending the request
+// to another node. With the percentile, the delay is computed from the
+// latencies observed and the fixed delay is used until enough
+// latencies are available.
+func (s *RestService) GetHedgeDelay() time.Duration {
+	if s.HedgePercentile > 0 {
+		s.latencyMutex.Lock()
+		d, ok := s.latencies.percentile(s.HedgePercentile)
+		s.latencyMutex.Unlock()
+		if ok {
+			return d
+		}
+	}
+	if s.HedgeDelayMs > 0 {
+		return time.Duration(s.HedgeDelayMs) * time.Millisecond
+	}
+	return DefaultHedgeDelay
+}
+
+func (s *RestService) GetHedgeMaxInFlight() int {
+	if s.HedgeMaxInFlight > 0 {
+		return s.HedgeMaxInFlight
+	}
+	return DefaultHedgeMaxInFlight
+}
+
+// AcquireHedge reserves a slot for an extra request. It returns false
+// if the max number of hedged requests in flight is reached.
+func (s *RestService) AcquireHedge() bool {
+	for {
+		n := s.hedgesInFlight.Load()
+		if n >= int64(s.GetHedgeMaxInFlight()) {
+			return false
+		}
+		if s.hedgesInFlight.CompareAndSwap(n, n+1) {
+			return true
+		}
+	}
+}
+
+func (s *RestService) ReleaseHedge() { s.hedgesInFlight.Add(-1) }
+
+func (s *RestService) GetHedgesInFlight() int {
+	return int(s.hedgesInFlight.Load())
+}
+
+// SetHedging reads the hedging options of the service.
+// The options override the Hedge* fields values.
+func (s *RestService) SetHedging() error {
+	if v, err := s.GetOption(ServiceHedgeDelayMs); err == nil {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid hedge delay option: %s", err.Error())
+		}
+		s.HedgeDelayMs = ms
+	}
+	if v, err := s.GetOption(ServiceHedgePercentile); err == nil {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid hedge percentile option: %s", err.Error())
+		}
+		s.HedgePercentile = p
+	}
+	if v, err := s.GetOption(ServiceHedgeMaxInFlight); err == nil {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid hedge max in flight option: %s", err.Error())
+		}
+		s.HedgeMaxInFlight = n
+	}
+
+	if s.HedgeDelayMs < 0 {
+		return fmt.Errorf("Invalid hedge delay %d", s.HedgeDelayMs)
+	}
+	if s.HedgePercentile < 0 || s.HedgePercentile > 100 {
+		return fmt.Errorf("Invalid hedge percentile %v", s.HedgePercentile)
+	}
+	if s.HedgeMaxInFlight < 0 {
+		return fmt.Errorf("Invalid hedge max in flight %d", s.HedgeMaxInFlight)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"context"
+	"net/http"
+)
+
+// AttemptFunc executes a single attempt of the ticket with the
+// request in input.
+type AttemptFunc func(t *RestTicket, req *http.Request) (*http.Response, error)
+
+// TicketFunc executes the whole ticket with all the retries.
+type TicketFunc func(ctx context.Context, t *RestTicket) error
+
+// Middleware wraps the round trip of every attempt of a ticket.
+type Middleware interface {
+	WrapAttempt(next AttemptFunc) AttemptFunc
+}
+
+// TicketMiddleware is a Middleware that hooks also the
+// lifecycle of the ticket.
+type TicketMiddleware interface {
+	Middleware
+	WrapTicket(next TicketFunc) TicketFunc
+}
+
+// ValidationObserver is a Middleware notified with the outcome
+// of the response validator of every attempt with a response.
+type ValidationObserver interface {
+	Middleware
+	OnValidation(t *RestTicket, valid bool, err error)
+}
+
+// MiddlewareFunc permits to use a function as attempt Middleware.
+type MiddlewareFunc func(next AttemptFunc) AttemptFunc
+
+func (f MiddlewareFunc) WrapAttempt(next AttemptFunc) AttemptFunc {
+	return f(next)
+}
+
+// ChainAttempt wraps the function in input with the middlewares.
+// The first middleware is the outermost.
+func ChainAttempt(f AttemptFunc, mws ...Middleware) AttemptFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		f = mws[i].WrapAttempt(f)
+	}
+	return f
+}
+
+// ChainTicket wraps the function in input with the middlewares that
+// implement TicketMiddleware. The first middleware is the outermost.
+func ChainTicket(f TicketFunc, mws ...Middleware) TicketFunc {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if tm, ok := mws[i].(TicketMiddleware); ok {
+			f = tm.WrapTicket(f)
+		}
+	}
+	return f
+}
+
+// Use registers the middlewares of the service. They are executed
+// after the middlewares of the guard.
+func (s *RestService) Use(mws ...Middleware) {
+	s.Middlewares = append(s.Middlewares, mws...)
+}
+
+func (s *RestService) GetMiddlewares() []Middleware { return s.Middlewares }
@@ -6,6 +6,7 @@ package specs
 
 import (
 	"strings"
+	"time"
 )
 
 func NewRestNode(name, burl string, ssl bool) *RestNode {
@@ -20,8 +21,40 @@ func NewRestNode(name, burl string, ssl bool) *RestNode {
 	}
 }
 
-func (n *RestNode) IsActive() bool    { return !n.Disable }
+func (n *RestNode) IsActive() bool    { return !n.Disable && n.IsHealthy() }
 func (n *RestNode) SetDisable(b bool) { n.Disable = b }
+func (n *RestNode) IsHealthy() bool   { return !n.unhealthy.Load() }
+func (n *RestNode) SetHealthy(b bool) { n.unhealthy.Store(!b) }
+
+// GetWeight returns the weight of the node used by the weighted
+// round-robin selector. The default weight is 1.
+func (n *RestNode) GetWeight() int {
+	if n.Weight <= 0 {
+		return 1
+	}
+	return n.Weight
+}
+
+// SetCooldown marks the node as cooling-down until the time in input.
+func (n *RestNode) SetCooldown(until time.Time) { n.cooldown.Store(until.UnixNano()) }
+
+// GetCooldown returns the remaining interval of the cooldown.
+func (n *RestNode) GetCooldown() time.Duration {
+	until := n.cooldown.Load()
+	if until == 0 {
+		return 0
+	}
+	d := time.Until(time.Unix(0, until))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (n *RestNode) IsCoolingDown() bool { return n.GetCooldown() > 0 }
+
+func (n *RestNode) GetOutstanding() int64      { return n.outstanding.Load() }
+func (n *RestNode) AddOutstanding(delta int64) { n.outstanding.Add(delta) }
 
 func (n *RestNode) GetUrlPrefix() string {
 	ans := ""
@@ -0,0 +1,100 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ParseRateSpec parses a rate limit spec with the syntax
+// <reqs>/<s|m|h> [burst=<n>], for example: 100/s, 3000/m burst=50.
+// The default burst is the number of requests of a second.
+func ParseRateSpec(spec string) (rate.Limit, int, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, 0, fmt.Errorf("Invalid rate spec %q", spec)
+	}
+
+	idx := strings.Index(fields[0], "/")
+	if idx <= 0 {
+		return 0, 0, fmt.Errorf("Invalid rate spec %q: missing unit", spec)
+	}
+	reqs, err := strconv.ParseFloat(fields[0][0:idx], 64)
+	if err != nil || reqs <= 0 {
+		return 0, 0, fmt.Errorf("Invalid rate spec %q: invalid requests", spec)
+	}
+
+	var unit time.Duration
+	switch fields[0][idx+1:] {
+	case "s", "sec":
+		unit = time.Second
+	case "m", "min":
+		unit = time.Minute
+	case "h", "hour":
+		unit = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("Invalid rate spec %q: invalid unit", spec)
+	}
+	limit := rate.Limit(reqs / unit.Seconds())
+
+	burst := int(math.Max(1, math.Ceil(float64(limit))))
+	if len(fields) == 2 {
+		v, ok := strings.CutPrefix(fields[1], "burst=")
+		if !ok {
+			return 0, 0, fmt.Errorf("Invalid rate spec %q: unknown parameter", spec)
+		}
+		burst, err = strconv.Atoi(v)
+		if err != nil || burst <= 0 {
+			return 0, 0, fmt.Errorf("Invalid rate spec %q: invalid burst", spec)
+		}
+	}
+
+	return limit, burst, nil
+}
+
+// parseRate parses a rate limit spec or a bare integer N that
+// is the same of the spec N/s.
+func parseRate(spec string) (rate.Limit, int, error) {
+	if reqs, err := strconv.Atoi(strings.TrimSpace(spec)); err == nil {
+		if reqs <= 0 {
+			return 0, 0, fmt.Errorf("Invalid rate spec %q: invalid requests", spec)
+		}
+		return rate.Limit(reqs), reqs, nil
+	}
+	return ParseRateSpec(spec)
+}
+
+// NewRateLimiterFromSpec returns the limiter of the rate limit spec.
+// A bare integer N is N requests every second with a burst of N.
+func NewRateLimiterFromSpec(spec string) (*rate.Limiter, error) {
+	limit, burst, err := parseRate(spec)
+	if err != nil {
+		return nil, err
+	}
+	return rate.NewLimiter(limit, burst), nil
+}
+
+func (n *RestNode) HasRateLimiter() bool          { return n.RateLimiter != nil }
+func (n *RestNode) GetRateLimiter() *rate.Limiter { return n.RateLimiter }
+
+// SetRateLimiter creates the limiter of the node from RateLimit.
+func (n *RestNode) SetRateLimiter() error {
+	if n.RateLimit == "" {
+		n.RateLimiter = nil
+		return nil
+	}
+	l, err := NewRateLimiterFromSpec(n.RateLimit)
+	if err != nil {
+		return err
+	}
+	n.RateLimiter = l
+	return nil
+}
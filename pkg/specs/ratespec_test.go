@@ -0,0 +1,61 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs_test
+
+import (
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+)
+
+var _ = Describe("Rate Spec Test", func() {
+
+	DescribeTable("Valid specs",
+		func(spec string, limit rate.Limit, burst int) {
+			l, b, err := specs.ParseRateSpec(spec)
+			Expect(err).Should(BeNil())
+			Expect(l).Should(BeNumerically("~", limit, 1e-9))
+			Expect(b).Should(Equal(burst))
+		},
+		Entry("per second", "100/s", rate.Limit(100), 100),
+		Entry("per minute with burst", "3000/m burst=50", rate.Limit(50), 50),
+		Entry("per hour", "10/h", rate.Limit(10.0/3600), 1),
+		Entry("long unit", "2/sec", rate.Limit(2), 2),
+	)
+
+	DescribeTable("Invalid specs",
+		func(spec string) {
+			_, _, err := specs.ParseRateSpec(spec)
+			Expect(err).ShouldNot(BeNil())
+		},
+		Entry("empty", ""),
+		Entry("without unit", "100"),
+		Entry("invalid unit", "100/d"),
+		Entry("invalid requests", "-1/s"),
+		Entry("invalid burst", "10/s burst=0"),
+		Entry("unknown parameter", "10/s foo=1"),
+	)
+
+	It("Read the bare integer as requests per second", func() {
+		l, err := specs.NewRateLimiterFromSpec("10")
+		Expect(err).Should(BeNil())
+		Expect(l.Limit()).Should(Equal(rate.Limit(10)))
+		Expect(l.Burst()).Should(Equal(10))
+
+		_, err = specs.NewRateLimiterFromSpec("0")
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	It("Setup the service limiter", func() {
+		s := specs.NewRestService("test")
+		s.SetOption(specs.ServiceRateLimiter, "3000/m burst=50")
+		Expect(s.SetRateLimiter()).Should(BeNil())
+		Expect(s.GetRateLimit()).Should(BeNumerically("~", 50, 1e-9))
+		Expect(s.GetRateLimiter().Burst()).Should(Equal(50))
+	})
+
+})
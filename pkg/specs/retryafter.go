@@ -0,0 +1,88 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Wait the Retry-After interval and retry on the same node.
+	RetryAfterWait = "wait"
+	// Mark the node as cooling-down for all the tickets and
+	// retry on the next node.
+	RetryAfterCooldown = "cooldown"
+)
+
+// ParseRetryAfter returns the interval of the Retry-After header of
+// the 429 and 503 responses. The header could be in seconds or
+// an HTTP-date.
+func ParseRetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil ||
+		(resp.StatusCode != http.StatusTooManyRequests &&
+			resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(date)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+func (s *RestService) HasRetryAfter() bool {
+	return s.RetryAfterMode != ""
+}
+
+// GetRetryAfterMax returns the max interval accepted from the
+// Retry-After header. Zero means no limit.
+func (s *RestService) GetRetryAfterMax() time.Duration {
+	return time.Duration(s.RetryAfterMaxMs) * time.Millisecond
+}
+
+// SetRetryAfter reads the Retry-After options of the service.
+// The options override the RetryAfter* fields values.
+func (s *RestService) SetRetryAfter() error {
+	if v, err := s.GetOption(ServiceRetryAfterMode); err == nil {
+		s.RetryAfterMode = v
+	}
+	if v, err := s.GetOption(ServiceRetryAfterMaxMs); err == nil {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid retry after max option: %s", err.Error())
+		}
+		s.RetryAfterMaxMs = ms
+	}
+
+	switch s.RetryAfterMode {
+	case RetryAfterWait, RetryAfterCooldown:
+	case "":
+		return fmt.Errorf("No retry after mode available")
+	default:
+		return fmt.Errorf("Invalid retry after mode %s", s.RetryAfterMode)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// Retry all the methods on every failure.
+	RetryPolicyAlways = "always"
+	// Retry only the idempotent methods.
+	RetryPolicyIdempotent = "idempotent"
+	// Retry the not idempotent methods only when the connection
+	// to the node is not established.
+	RetryPolicyConnectErrors = "connect-errors"
+
+	IdempotencyKeyHeader = "Idempotency-Key"
+)
+
+// IsIdempotentMethod returns true for the methods defined
+// idempotent by RFC 9110.
+func IsIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (s *RestService) GetRetryPolicy() string {
+	if s.RetryPolicy == "" {
+		return RetryPolicyAlways
+	}
+	return s.RetryPolicy
+}
+
+// CanRetry returns true if the request could be retried with
+// the retry policy of the service. The requests with the
+// Idempotency-Key header are managed as idempotent.
+func (s *RestService) CanRetry(req *http.Request, connectErr bool) bool {
+	if req == nil {
+		return false
+	}
+	if IsIdempotentMethod(req.Method) || req.Header.Get(IdempotencyKeyHeader) != "" {
+		return true
+	}
+
+	switch s.GetRetryPolicy() {
+	case RetryPolicyIdempotent:
+		return false
+	case RetryPolicyConnectErrors:
+		return connectErr
+	}
+	return true
+}
+
+// SetRetryPolicy reads the retry policy options of the service.
+// The options override the fields values.
+func (s *RestService) SetRetryPolicy() error {
+	if v, err := s.GetOption(ServiceRetryPolicy); err == nil {
+		s.RetryPolicy = v
+	}
+	if v, err := s.GetOption(ServiceIdempotencyKey); err == nil {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("Invalid idempotency key option: %s", err.Error())
+		}
+		s.IdempotencyKey = b
+	}
+
+	switch s.RetryPolicy {
+	case "", RetryPolicyAlways, RetryPolicyIdempotent, RetryPolicyConnectErrors:
+	default:
+		return fmt.Errorf("Invalid retry policy %s", s.RetryPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	SelectorRoundRobin         = "round-robin"
+	SelectorWeightedRoundRobin = "weighted-round-robin"
+	SelectorRandom             = "random"
+	SelectorPowerOfTwo         = "power-of-two"
+	SelectorLeastOutstanding   = "least-outstanding"
+)
+
+// NodeSelector chooses the node to use for a ticket.
+type NodeSelector interface {
+	// Select returns the node to use between the nodes in input.
+	// The slice is never empty.
+	Select(t *RestTicket, nodes []*RestNode) *RestNode
+}
+
+// RoundRobinSelector shares the counter between all the tickets
+// of the service.
+type RoundRobinSelector struct {
+	counter atomic.Uint64
+}
+
+func (s *RoundRobinSelector) Select(t *RestTicket, nodes []*RestNode) *RestNode {
+	idx := s.counter.Add(1) - 1
+	return nodes[idx%uint64(len(nodes))]
+}
+
+// WeightedRoundRobinSelector implements the smooth weighted
+// round-robin algorithm based on the Weight of the nodes.
+type WeightedRoundRobinSelector struct {
+	mutex   sync.Mutex
+	current map[string]int
+}
+
+func (s *WeightedRoundRobinSelector) Select(t *RestTicket, nodes []*RestNode) *RestNode {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.current == nil {
+		s.current = make(map[string]int, 0)
+	}
+
+	var ans *RestNode
+	total := 0
+	for _, n := range nodes {
+		w := n.GetWeight()
+		total += w
+		s.current[n.Name] += w
+		if ans == nil || s.current[n.Name] > s.current[ans.Name] {
+			ans = n
+		}
+	}
+	s.current[ans.Name] -= total
+
+	return ans
+}
+
+type RandomSelector struct{}
+
+func (s *RandomSelector) Select(t *RestTicket, nodes []*RestNode) *RestNode {
+	return nodes[rand.Intn(len(nodes))]
+}
+
+// PowerOfTwoSelector chooses two random nodes and returns the node
+// with less outstanding requests.
+type PowerOfTwoSelector struct{}
+
+func (s *PowerOfTwoSelector) Select(t *RestTicket, nodes []*RestNode) *RestNode {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	i := rand.Intn(len(nodes))
+	j := rand.Intn(len(nodes) - 1)
+	if j >= i {
+		j++
+	}
+	if nodes[j].GetOutstanding() < nodes[i].GetOutstanding() {
+		return nodes[j]
+	}
+	return nodes[i]
+}
+
+// LeastOutstandingSelector returns the node with less outstanding
+// requests. On parity the first node is used.
+type LeastOutstandingSelector struct{}
+
+func (s *LeastOutstandingSelector) Select(t *RestTicket, nodes []*RestNode) *RestNode {
+	ans := nodes[0]
+	for _, n := range nodes[1:] {
+		if n.GetOutstanding() < ans.GetOutstanding() {
+			ans = n
+		}
+	}
+	return ans
+}
+
+func NewNodeSelector(name string) (NodeSelector, error) {
+	switch name {
+	case SelectorRoundRobin:
+		return &RoundRobinSelector{}, nil
+	case SelectorWeightedRoundRobin:
+		return &WeightedRoundRobinSelector{}, nil
+	case SelectorRandom:
+		return &RandomSelector{}, nil
+	case SelectorPowerOfTwo:
+		return &PowerOfTwoSelector{}, nil
+	case SelectorLeastOutstanding:
+		return &LeastOutstandingSelector{}, nil
+	default:
+		return nil, fmt.Errorf("Invalid node selector %s", name)
+	}
+}
+
+func (s *RestService) HasNodeSelector() bool {
+	return s.NodeSelector != nil
+}
+
+func (s *RestService) GetNodeSelector() NodeSelector    { return s.NodeSelector }
+func (s *RestService) SetNodeSelector(sel NodeSelector) { s.NodeSelector = sel }
+
+// SetSelector creates the node selector of the service from the
+// selector option or from the Selector field.
+func (s *RestService) SetSelector() error {
+	if v, err := s.GetOption(ServiceSelector); err == nil {
+		s.Selector = v
+	}
+
+	if s.Selector == "" {
+		return fmt.Errorf("No node selector available")
+	}
+
+	sel, err := NewNodeSelector(s.Selector)
+	if err != nil {
+		return err
+	}
+	s.NodeSelector = sel
+	return nil
+}
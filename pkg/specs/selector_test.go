@@ -0,0 +1,89 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs_test
+
+import (
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Node Selectors Test", func() {
+
+	var (
+		n1, n2, n3 *specs.RestNode
+		nodes      []*specs.RestNode
+	)
+
+	BeforeEach(func() {
+		n1 = specs.NewRestNode("n1", "127.0.0.1:8001", false)
+		n2 = specs.NewRestNode("n2", "127.0.0.1:8002", false)
+		n3 = specs.NewRestNode("n3", "127.0.0.1:8003", false)
+		nodes = []*specs.RestNode{n1, n2, n3}
+	})
+
+	names := func(sel specs.NodeSelector, n int) []string {
+		ans := []string{}
+		for i := 0; i < n; i++ {
+			ans = append(ans, sel.Select(nil, nodes).Name)
+		}
+		return ans
+	}
+
+	It("Round robin", func() {
+		sel, err := specs.NewNodeSelector(specs.SelectorRoundRobin)
+		Expect(err).Should(BeNil())
+		Expect(names(sel, 4)).Should(Equal([]string{"n1", "n2", "n3", "n1"}))
+	})
+
+	It("Weighted round robin", func() {
+		n1.Weight = 3
+		nodes = []*specs.RestNode{n1, n2}
+		sel, err := specs.NewNodeSelector(specs.SelectorWeightedRoundRobin)
+		Expect(err).Should(BeNil())
+		Expect(names(sel, 8)).Should(Equal([]string{
+			"n1", "n1", "n2", "n1",
+			"n1", "n1", "n2", "n1",
+		}))
+	})
+
+	It("Random", func() {
+		sel, err := specs.NewNodeSelector(specs.SelectorRandom)
+		Expect(err).Should(BeNil())
+		for _, n := range names(sel, 10) {
+			Expect([]string{"n1", "n2", "n3"}).Should(ContainElement(n))
+		}
+	})
+
+	It("Least outstanding", func() {
+		n1.AddOutstanding(2)
+		n2.AddOutstanding(1)
+		n3.AddOutstanding(3)
+		sel, err := specs.NewNodeSelector(specs.SelectorLeastOutstanding)
+		Expect(err).Should(BeNil())
+		Expect(sel.Select(nil, nodes).Name).Should(Equal("n2"))
+	})
+
+	It("Power of two choices", func() {
+		n1.AddOutstanding(5)
+		nodes = []*specs.RestNode{n1, n2}
+		sel, err := specs.NewNodeSelector(specs.SelectorPowerOfTwo)
+		Expect(err).Should(BeNil())
+		Expect(names(sel, 5)).Should(Equal([]string{"n2", "n2", "n2", "n2", "n2"}))
+	})
+
+	It("Service option", func() {
+		s := specs.NewRestService("service1")
+		Expect(s.SetSelector()).ShouldNot(BeNil())
+		s.SetOption(specs.ServiceSelector, "foo")
+		Expect(s.SetSelector()).ShouldNot(BeNil())
+		s.SetOption(specs.ServiceSelector, specs.SelectorRoundRobin)
+		Expect(s.SetSelector()).Should(BeNil())
+		Expect(s.HasNodeSelector()).Should(BeTrue())
+		Expect(s.Clone().GetNodeSelector()).ShouldNot(BeIdenticalTo(s.GetNodeSelector()))
+	})
+
+})
@@ -71,13 +71,68 @@ func (s *RestService) SetRateLimiter() error {
 	}
 
 	// Setup a new Rate Limiter
-	reqs, err := strconv.Atoi(v)
+	limiter, err := NewRateLimiterFromSpec(v)
 	if err != nil {
 		return fmt.Errorf("Invalid rate limits option: %s", err.Error())
 	}
 
-	// N reqs every second
-	s.RateLimiter = rate.NewLimiter(rate.Every(1*time.Second), reqs)
+	mode, _ := s.GetOption(ServiceRateLimiterMode)
+	switch mode {
+	case "", RateLimiterStatic:
+	case RateLimiterAdaptive:
+		// The option is the initial rate.
+		return s.setAdaptiveLimiter(float64(limiter.Limit()))
+	default:
+		return fmt.Errorf("Invalid rate limiter mode %s", mode)
+	}
+
+	s.RateLimiter = limiter
+	s.AdaptiveLimiter = nil
+	return nil
+}
+
+func (s *RestService) HasBackoffPolicy() bool {
+	return s.BackoffPolicy != nil
+}
+
+func (s *RestService) GetBackoffPolicy() BackoffPolicy  { return s.BackoffPolicy }
+func (s *RestService) SetBackoffPolicy(p BackoffPolicy) { s.BackoffPolicy = p }
+
+// SetBackoff creates the backoff policy of the service from the
+// backoff options or from the Backoff* fields. The options override
+// the fields values.
+func (s *RestService) SetBackoff() error {
+	if v, err := s.GetOption(ServiceBackoff); err == nil {
+		s.Backoff = v
+	}
+	if v, err := s.GetOption(ServiceBackoffMaxIntervalMs); err == nil {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("Invalid backoff max interval option: %s", err.Error())
+		}
+		s.BackoffMaxIntervalMs = ms
+	}
+	if v, err := s.GetOption(ServiceBackoffMultiplier); err == nil {
+		mult, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid backoff multiplier option: %s", err.Error())
+		}
+		s.BackoffMultiplier = mult
+	}
+
+	if s.Backoff == "" {
+		return fmt.Errorf("No backoff policy available")
+	}
+
+	p, err := NewBackoffPolicy(s.Backoff,
+		time.Duration(s.RetryIntervalMs)*time.Millisecond,
+		time.Duration(s.BackoffMaxIntervalMs)*time.Millisecond,
+		s.BackoffMultiplier,
+	)
+	if err != nil {
+		return err
+	}
+	s.BackoffPolicy = p
 	return nil
 }
 
@@ -106,15 +161,73 @@ func (s *RestService) Clone() *RestService {
 		RespValidatorCb: s.RespValidatorCb,
 		RetryIntervalMs: s.RetryIntervalMs,
 		Options:         make(map[string]string, 0),
+
+		Backoff:              s.Backoff,
+		BackoffMaxIntervalMs: s.BackoffMaxIntervalMs,
+		BackoffMultiplier:    s.BackoffMultiplier,
+		BackoffPolicy:        s.BackoffPolicy,
+
+		BreakerFailureThreshold: s.BreakerFailureThreshold,
+		BreakerOpenMs:           s.BreakerOpenMs,
+		BreakerHalfOpenProbes:   s.BreakerHalfOpenProbes,
+		BreakerStateCb:          s.BreakerStateCb,
+
+		RetryAfterMode:  s.RetryAfterMode,
+		RetryAfterMaxMs: s.RetryAfterMaxMs,
+
+		RetryPolicy:    s.RetryPolicy,
+		IdempotencyKey: s.IdempotencyKey,
+
+		HedgeDelayMs:     s.HedgeDelayMs,
+		HedgePercentile:  s.HedgePercentile,
+		HedgeMaxInFlight: s.HedgeMaxInFlight,
+
+		BulkheadMaxConcurrent: s.BulkheadMaxConcurrent,
+		BulkheadMaxQueue:      s.BulkheadMaxQueue,
+		BulkheadWaitMs:        s.BulkheadWaitMs,
+
+		// The cached responses are shared with the clone.
+		Cache:           s.Cache,
+		CacheDir:        s.CacheDir,
+		CacheMaxEntries: s.CacheMaxEntries,
+		ResponseCache:   s.ResponseCache,
+
+		BodySpoolDir: s.BodySpoolDir,
+
+		Selector:     s.Selector,
+		NodeSelector: s.NodeSelector,
+		Middlewares:  append([]Middleware{}, s.Middlewares...),
+
+		Auth:          s.Auth,
+		Authenticator: s.Authenticator,
+		TLS:           s.TLS,
+	}
+
+	if s.Selector != "" {
+		// Avoid to share the selector state between the services.
+		if sel, err := NewNodeSelector(s.Selector); err == nil {
+			ans.NodeSelector = sel
+		}
+	}
+
+	if s.Bulkhead != nil {
+		// The clone has its own in flight tickets.
+		ans.Bulkhead = NewBulkhead(cap(s.Bulkhead.slots), int(s.Bulkhead.maxQueue),
+			s.Bulkhead.waitTime)
+	}
+
+	if s.HealthCheck != nil {
+		hc := *s.HealthCheck
+		ans.HealthCheck = &hc
 	}
 
 	for k, v := range s.Options {
 		ans.Options[k] = v
+	}
 
-		if k == ServiceRateLimiter {
-			// Ignoring error. I assume that the value is correct.
-			ans.SetRateLimiter()
-		}
+	if ans.HasOption(ServiceRateLimiter) {
+		// Ignoring error. I assume that the value is correct.
+		ans.SetRateLimiter()
 	}
 
 	return ans
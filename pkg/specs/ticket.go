@@ -38,6 +38,11 @@ func (t *RestTicket) Rip() {
 	if t.Response != nil {
 		t.Response.Body.Close()
 	}
+	if t.Request != nil && t.Request.Body != nil {
+		// The body of a request never sent is still open.
+		t.Request.Body.Close()
+	}
+	t.body.cleanup()
 }
 
 // Facility function to get HTTP response status code
@@ -0,0 +1,112 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package specs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RestTLS describes the TLS profile of a service or of a node.
+type RestTLS struct {
+	// The CA bundle used to verify the server certificate as
+	// file or as PEM data. Default is the system pool.
+	CaFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty" mapstructure:"ca_file,omitempty"`
+	CaPem  string `json:"ca_pem,omitempty" yaml:"ca_pem,omitempty" mapstructure:"ca_pem,omitempty"`
+
+	// The client certificate and key for mTLS as files or as PEM data.
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty" mapstructure:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty" mapstructure:"key_file,omitempty"`
+	CertPem  string `json:"cert_pem,omitempty" yaml:"cert_pem,omitempty" mapstructure:"cert_pem,omitempty"`
+	KeyPem   string `json:"key_pem,omitempty" yaml:"key_pem,omitempty" mapstructure:"key_pem,omitempty"`
+
+	// The min TLS version: 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string `json:"min_version,omitempty" yaml:"min_version,omitempty" mapstructure:"min_version,omitempty"`
+	ServerName string `json:"server_name,omitempty" yaml:"server_name,omitempty" mapstructure:"server_name,omitempty"`
+
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty" mapstructure:"insecure_skip_verify,omitempty"`
+}
+
+// GetKey returns a string that identifies the profile.
+func (c *RestTLS) GetKey() string {
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+func parseTLSVersion(v string) (uint16, error) {
+	switch v {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("invalid TLS version %s", v)
+}
+
+// NewConfig returns the tls.Config of the profile.
+func (c *RestTLS) NewConfig() (*tls.Config, error) {
+	minVersion, err := parseTLSVersion(c.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	ans := &tls.Config{
+		MinVersion:         minVersion,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CaFile != "" || c.CaPem != "" {
+		pool := x509.NewCertPool()
+		if c.CaFile != "" {
+			data, err := os.ReadFile(c.CaFile)
+			if err != nil {
+				return nil, fmt.Errorf("error on read file %s: %s", c.CaFile, err.Error())
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in %s", c.CaFile)
+			}
+		}
+		if c.CaPem != "" && !pool.AppendCertsFromPEM([]byte(c.CaPem)) {
+			return nil, fmt.Errorf("no certificates found in ca_pem")
+		}
+		ans.RootCAs = pool
+	}
+
+	var cert tls.Certificate
+	switch {
+	case c.CertFile != "" || c.KeyFile != "":
+		cert, err = tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error on load client certificate: %s", err.Error())
+		}
+		ans.Certificates = []tls.Certificate{cert}
+	case c.CertPem != "" || c.KeyPem != "":
+		cert, err = tls.X509KeyPair([]byte(c.CertPem), []byte(c.KeyPem))
+		if err != nil {
+			return nil, fmt.Errorf("error on parse client certificate: %s", err.Error())
+		}
+		ans.Certificates = []tls.Certificate{cert}
+	}
+
+	return ans, nil
+}
+
+// GetTLS returns the TLS profile of the node or of the service.
+func (s *RestService) GetTLS(n *RestNode) *RestTLS {
+	if n != nil && n.TLS != nil {
+		return n.TLS
+	}
+	return s.TLS
+}
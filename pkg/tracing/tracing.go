@@ -0,0 +1,128 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/geaaru/rest-guard/pkg/specs"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TracerName = "github.com/geaaru/rest-guard"
+
+	TicketSpanName  = "restguard.ticket"
+	AttemptSpanName = "restguard.attempt"
+
+	AttrTicketId   = attribute.Key("restguard.ticket.id")
+	AttrService    = attribute.Key("restguard.service")
+	AttrRetries    = attribute.Key("restguard.retries")
+	AttrAttempt    = attribute.Key("restguard.attempt")
+	AttrNode       = attribute.Key("restguard.node")
+	AttrValid      = attribute.Key("restguard.response.valid")
+	AttrMethod     = attribute.Key("http.request.method")
+	AttrUrl        = attribute.Key("url.full")
+	AttrStatusCode = attribute.Key("http.response.status_code")
+)
+
+// Middleware creates a span for every ticket and a child span
+// for every attempt. The W3C trace context is injected in the
+// headers of the requests.
+type Middleware struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewMiddleware returns the tracing middleware. With a nil provider
+// the global OpenTelemetry provider is used. With a nil propagator
+// the W3C trace context is used.
+func NewMiddleware(tp trace.TracerProvider, prop propagation.TextMapPropagator) *Middleware {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	if prop == nil {
+		prop = propagation.TraceContext{}
+	}
+	return &Middleware{
+		tracer:     tp.Tracer(TracerName),
+		propagator: prop,
+	}
+}
+
+func (m *Middleware) WrapTicket(next specs.TicketFunc) specs.TicketFunc {
+	return func(ctx context.Context, t *specs.RestTicket) error {
+		ctx, span := m.tracer.Start(ctx, TicketSpanName,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				AttrTicketId.String(t.GetId()),
+				AttrService.String(t.GetService().GetName()),
+			))
+		defer span.End()
+
+		err := next(ctx, t)
+
+		span.SetAttributes(AttrRetries.Int(t.GetRetries()))
+		if t.GetNode() != nil {
+			span.SetAttributes(AttrNode.String(t.GetNode().Name))
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+func (m *Middleware) WrapAttempt(next specs.AttemptFunc) specs.AttemptFunc {
+	return func(t *specs.RestTicket, req *http.Request) (*http.Response, error) {
+		attrs := []attribute.KeyValue{
+			AttrAttempt.Int(t.GetRetries() + 1),
+			AttrMethod.String(req.Method),
+			AttrUrl.String(req.URL.String()),
+		}
+		if t.GetNode() != nil {
+			attrs = append(attrs, AttrNode.String(t.GetNode().Name))
+		}
+
+		ctx, span := m.tracer.Start(req.Context(), AttemptSpanName,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...))
+
+		m.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+		resp, err := next(t, req.WithContext(ctx))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.End()
+			return resp, err
+		}
+
+		// The span is closed by OnValidation.
+		span.SetAttributes(AttrStatusCode.Int(resp.StatusCode))
+		return resp, err
+	}
+}
+
+func (m *Middleware) OnValidation(t *specs.RestTicket, valid bool, err error) {
+	span := trace.SpanFromContext(t.GetRequest().Context())
+	span.SetAttributes(AttrValid.Bool(valid))
+	if !valid {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetStatus(codes.Error, "invalid response")
+		}
+	}
+	span.End()
+}
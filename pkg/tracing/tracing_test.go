@@ -0,0 +1,121 @@
+/*
+Copyright © 2025 Macaroni OS Linux
+See AUTHORS and LICENSE for the license details and contributors.
+*/
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+
+	g "github.com/geaaru/rest-guard/pkg/guard"
+	"github.com/geaaru/rest-guard/pkg/specs"
+	"github.com/geaaru/rest-guard/pkg/tracing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttr(s tracetest.SpanStub, k attribute.Key) attribute.Value {
+	for _, a := range s.Attributes {
+		if a.Key == k {
+			return a.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+var _ = Describe("Tracing Tests", func() {
+
+	var (
+		server   *ghttp.Server
+		guard    *g.RestGuard
+		service  *specs.RestService
+		exporter *tracetest.InMemoryExporter
+		provider *sdktrace.TracerProvider
+	)
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		server.RouteToHandler("GET", "/ok",
+			ghttp.CombineHandlers(
+				func(w http.ResponseWriter, r *http.Request) {
+					Expect(r.Header.Get("traceparent")).ShouldNot(BeEmpty())
+				},
+				ghttp.RespondWith(http.StatusOK, "OK"),
+			))
+		server.RouteToHandler("GET", "/ko", ghttp.RespondWith(http.StatusNotFound, "KO"))
+
+		exporter = tracetest.NewInMemoryExporter()
+		provider = sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+		guard, err = g.NewRestGuard(specs.NewConfig())
+		Expect(err).Should(BeNil())
+		guard.Use(tracing.NewMiddleware(provider, nil))
+
+		service = specs.NewRestService("local-tester")
+		service.Retries = 2
+		guard.AddService(service.GetName(), service)
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("failed", "127.0.0.1:10000", false))).Should(BeNil())
+		Expect(guard.AddRestNode(service.GetName(),
+			specs.NewRestNode("LocalServer", server.Addr(), false))).Should(BeNil())
+	})
+
+	AfterEach(func() {
+		provider.Shutdown(context.Background())
+		server.Close()
+	})
+
+	It("Create the spans of ticket and attempts", func() {
+		t := service.GetTicket()
+		defer t.Rip()
+		_, err := guard.CreateRequest(t, "GET", "/ok")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).Should(BeNil())
+
+		spans := exporter.GetSpans()
+		Expect(spans).Should(HaveLen(3))
+
+		// The spans are exported on end.
+		attempt1, attempt2, ticket := spans[0], spans[1], spans[2]
+		Expect(ticket.Name).Should(Equal(tracing.TicketSpanName))
+		Expect(spanAttr(ticket, tracing.AttrTicketId).AsString()).Should(Equal(t.Id))
+		Expect(spanAttr(ticket, tracing.AttrService).AsString()).Should(Equal("local-tester"))
+		Expect(spanAttr(ticket, tracing.AttrRetries).AsInt64()).Should(Equal(int64(1)))
+
+		Expect(attempt1.Name).Should(Equal(tracing.AttemptSpanName))
+		Expect(attempt1.Parent.SpanID()).Should(Equal(ticket.SpanContext.SpanID()))
+		Expect(spanAttr(attempt1, tracing.AttrNode).AsString()).Should(Equal("failed"))
+		Expect(attempt1.Events).ShouldNot(BeEmpty())
+
+		Expect(attempt2.Parent.SpanID()).Should(Equal(ticket.SpanContext.SpanID()))
+		Expect(spanAttr(attempt2, tracing.AttrNode).AsString()).Should(Equal("LocalServer"))
+		Expect(spanAttr(attempt2, tracing.AttrAttempt).AsInt64()).Should(Equal(int64(2)))
+		Expect(spanAttr(attempt2, tracing.AttrStatusCode).AsInt64()).Should(Equal(int64(200)))
+		Expect(spanAttr(attempt2, tracing.AttrValid).AsBool()).Should(BeTrue())
+		Expect(spanAttr(attempt2, tracing.AttrUrl).AsString()).Should(HaveSuffix("/ok"))
+	})
+
+	It("Record the validator rejections", func() {
+		service.Retries = 0
+		t := service.GetTicket()
+		defer t.Rip()
+		t.Node = service.GetNodes()[1]
+		_, err := guard.CreateRequest(t, "GET", "/ko")
+		Expect(err).Should(BeNil())
+		Expect(guard.Do(t)).ShouldNot(BeNil())
+
+		spans := exporter.GetSpans()
+		Expect(spans).Should(HaveLen(2))
+		Expect(spanAttr(spans[0], tracing.AttrValid).AsBool()).Should(BeFalse())
+		Expect(spanAttr(spans[0], tracing.AttrStatusCode).AsInt64()).Should(Equal(int64(404)))
+		Expect(spans[1].Status.Description).Should(Equal("Received invalid response"))
+	})
+
+})